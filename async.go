@@ -0,0 +1,172 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// 异步输出缓冲区写满时的处理策略
+const (
+	// AsyncDropOldest 丢弃最早的日志，业务协程永不阻塞
+	AsyncDropOldest = iota
+	// AsyncBlock 阻塞写入直到缓冲区有空位，不丢日志
+	AsyncBlock
+)
+
+// defaultAsyncBufferSize 默认缓冲的日志行数
+const defaultAsyncBufferSize = 8192
+
+// AsyncWriter 基于环形缓冲区的异步 writer
+// 写入只复制日志行到缓冲区，由后台协程批量写入下游，格式化后的写入不再占用请求耗时
+type AsyncWriter struct {
+	out    io.Writer
+	policy int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	ring    [][]byte
+	head    int
+	count   int
+	writing bool
+	closed  bool
+	done    chan struct{}
+
+	dropped atomic.Uint64
+	// closedMu 串行化关闭后的同步写入
+	closedMu sync.Mutex
+}
+
+// NewAsyncWriter 创建异步 writer，size 为缓冲的日志行数，小于等于 0 时使用默认值 8192
+// policy 为 AsyncDropOldest 或 AsyncBlock
+func NewAsyncWriter(out io.Writer, size int, policy int) *AsyncWriter {
+	if size <= 0 {
+		size = defaultAsyncBufferSize
+	}
+	w := &AsyncWriter{
+		out:    out,
+		policy: policy,
+		ring:   make([][]byte, size),
+		done:   make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// EnableAsyncOutput 将当前日志输出替换为异步输出，在 Init 之后调用
+// Fatal 退出前会自动写完缓冲区，正常退出前应调用返回值的 Close
+func EnableAsyncOutput(size int, policy int) *AsyncWriter {
+	w := NewAsyncWriter(currentOutput(), size, policy)
+	setOutput(w)
+	RegisterExitHandler(func() {
+		_ = w.Close()
+	})
+	return w
+}
+
+// Write 复制日志行到缓冲区
+// logrus 写入后会复用 p，因此必须复制
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return w.writeClosed(p)
+	}
+	for w.count == len(w.ring) {
+		if w.policy == AsyncBlock {
+			w.cond.Wait()
+			if w.closed {
+				w.mu.Unlock()
+				return w.writeClosed(p)
+			}
+			continue
+		}
+		// 丢弃最早的一行
+		w.ring[w.head] = nil
+		w.head = (w.head + 1) % len(w.ring)
+		w.count--
+		w.dropped.Add(1)
+	}
+	w.ring[(w.head+w.count)%len(w.ring)] = line
+	w.count++
+	w.cond.Broadcast()
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+// writeClosed 关闭后同步写入下游
+// 等待后台协程写完缓冲区后再写入，多个写入方之间串行，不会与后台协程并发写入下游
+func (w *AsyncWriter) writeClosed(p []byte) (int, error) {
+	<-w.done
+	w.closedMu.Lock()
+	defer w.closedMu.Unlock()
+	return w.out.Write(p)
+}
+
+// run 后台写入缓冲区中的日志
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	batch := make([][]byte, 0, len(w.ring))
+	for {
+		w.mu.Lock()
+		for w.count == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if w.count == 0 && w.closed {
+			w.mu.Unlock()
+			return
+		}
+		for w.count > 0 {
+			batch = append(batch, w.ring[w.head])
+			w.ring[w.head] = nil
+			w.head = (w.head + 1) % len(w.ring)
+			w.count--
+		}
+		w.writing = true
+		// 唤醒等待空位的写入方
+		w.cond.Broadcast()
+		w.mu.Unlock()
+
+		for _, line := range batch {
+			if _, err := w.out.Write(line); err != nil {
+				fmt.Fprintf(os.Stderr, "async log write failed: %v\n", err)
+			}
+		}
+		batch = batch[:0]
+
+		w.mu.Lock()
+		w.writing = false
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+// Flush 等待缓冲区中已写入的日志全部写入下游
+func (w *AsyncWriter) Flush() {
+	w.mu.Lock()
+	for (w.count > 0 || w.writing) && !w.closed {
+		w.cond.Wait()
+	}
+	w.mu.Unlock()
+}
+
+// Close 写完缓冲区后停止后台协程，之后的写入直接同步写入下游，不会关闭下游 writer
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+	<-w.done
+	return nil
+}
+
+// Dropped 返回因缓冲区写满被丢弃的日志行数
+func (w *AsyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrencyWriter 记录是否发生并发写入
+type concurrencyWriter struct {
+	active     atomic.Int32
+	concurrent atomic.Bool
+	lines      atomic.Int32
+}
+
+func (w *concurrencyWriter) Write(p []byte) (int, error) {
+	if w.active.Add(1) > 1 {
+		w.concurrent.Store(true)
+	}
+	time.Sleep(100 * time.Microsecond)
+	w.active.Add(-1)
+	w.lines.Add(1)
+	return len(p), nil
+}
+
+func TestAsyncBlockCloseSerializesWrites(t *testing.T) {
+	out := &concurrencyWriter{}
+	w := NewAsyncWriter(out, 1, AsyncBlock)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = w.Write([]byte("line\n"))
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	_ = w.Close()
+	wg.Wait()
+
+	if out.concurrent.Load() {
+		t.Error("downstream writer called concurrently")
+	}
+	if n := out.lines.Load(); n != 20 {
+		t.Errorf("wrote %d lines, want 20", n)
+	}
+}
+
+var benchLine = []byte(`{"level":"info","msg":"request finished","trace":"0123456789abcdef"}` + "\n")
+
+func BenchmarkAsyncWriter(b *testing.B) {
+	b.Run("sync", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = io.Discard.Write(benchLine)
+		}
+	})
+	b.Run("async", func(b *testing.B) {
+		w := NewAsyncWriter(io.Discard, 0, AsyncDropOldest)
+		defer w.Close()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = w.Write(benchLine)
+		}
+	})
+}
+
+func BenchmarkStaticBaseEntry(b *testing.B) {
+	b.Run("cached", func(b *testing.B) {
+		staticBaseEntry()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			staticBaseEntry()
+		}
+	})
+	b.Run("rebuild", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			resetStaticEntry()
+			staticBaseEntry()
+		}
+	})
+}
+
+func BenchmarkLog(b *testing.B) {
+	out := currentOutput()
+	defer setOutput(out)
+	ctx := context.Background()
+
+	b.Run("sync", func(b *testing.B) {
+		setOutput(io.Discard)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Log(ctx).Info("request finished")
+		}
+	})
+	b.Run("async", func(b *testing.B) {
+		w := NewAsyncWriter(io.Discard, 0, AsyncDropOldest)
+		defer w.Close()
+		setOutput(w)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Log(ctx).Info("request finished")
+		}
+	})
+}
@@ -0,0 +1,297 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/open4go/log/model/operation"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoAuditSink 未设置审计日志数据库或其它写入目标
+var ErrNoAuditSink = errors.New("no audit sink registered, call SetAuditDB or RegisterAuditSink first")
+
+// AuditRecord 审计记录
+type AuditRecord = operation.Model
+
+// AuditSink 审计记录的写入目标
+// 除内置的 MongoSink 外，可以实现该接口将审计事件投递到 Kafka/NATS 等消息队列，例如:
+//
+//	type KafkaSink struct{ Writer *kafka.Writer }
+//
+//	func (s *KafkaSink) Write(ctx context.Context, record log.AuditRecord) error {
+//		b, err := json.Marshal(record)
+//		if err != nil {
+//			return err
+//		}
+//		return s.Writer.WriteMessages(ctx, kafka.Message{Key: []byte(record.ID.Hex()), Value: b})
+//	}
+//
+//	log.RegisterAuditSink(&KafkaSink{Writer: w})
+//
+// 写入在调用方协程中同步执行，实现需要自行控制超时
+// 通过 AuditLogInSession 写入时注册的写入目标在事务提交后才写入
+type AuditSink interface {
+	Write(ctx context.Context, record AuditRecord) error
+}
+
+// MongoSink 将审计记录写入 MongoDB，表名为记录的 CollectionName()
+type MongoSink struct {
+	DB *mongo.Database
+}
+
+// Write 实现 AuditSink
+func (s *MongoSink) Write(ctx context.Context, record AuditRecord) error {
+	_, err := s.DB.Collection(record.CollectionName()).InsertOne(ctx, record)
+	return err
+}
+
+// auditSinkSet 审计写入目标，修改时整体替换，读取方持有的快照不受影响
+type auditSinkSet struct {
+	// mongo 通过 SetAuditDB 设置的 Mongo 写入目标
+	mongo AuditSink
+	// others 通过 RegisterAuditSink 注册的其它写入目标
+	others []AuditSink
+}
+
+var (
+	// auditSinks 当前的审计写入目标
+	auditSinks atomic.Pointer[auditSinkSet]
+	// auditSinksMu 串行化写入目标的修改
+	auditSinksMu sync.Mutex
+)
+
+// loadAuditSinks 返回当前写入目标的快照
+func loadAuditSinks() auditSinkSet {
+	if set := auditSinks.Load(); set != nil {
+		return *set
+	}
+	return auditSinkSet{}
+}
+
+// updateAuditSinks 复制当前写入目标，修改后替换
+func updateAuditSinks(update func(set *auditSinkSet)) {
+	auditSinksMu.Lock()
+	defer auditSinksMu.Unlock()
+	set := loadAuditSinks()
+	update(&set)
+	auditSinks.Store(&set)
+}
+
+// auditMethods 需要持久化的请求方法
+var auditMethods atomic.Pointer[map[string]bool]
+
+func init() {
+	SetAuditMethods(http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete)
+}
+
+// auditLogReads 不持久化的操作是否仍然输出到日志流
+var auditLogReads atomic.Bool
+
+// SetAuditMethods 设置需要持久化的请求方法，默认 POST/PUT/PATCH/DELETE
+// 其它方法(通常是 GET 等读操作)的操作日志不会写入数据库，避免审计表无限增长
+func SetAuditMethods(methods ...string) {
+	m := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		m[strings.ToUpper(method)] = true
+	}
+	auditMethods.Store(&m)
+}
+
+// SetAuditLogReads 设置不持久化的操作是否仍然以 info 日志输出，默认不输出
+func SetAuditLogReads(enabled bool) {
+	auditLogReads.Store(enabled)
+}
+
+// SetAuditDB 设置审计日志(操作日志)写入的数据库
+func SetAuditDB(db *mongo.Database) {
+	updateAuditSinks(func(set *auditSinkSet) {
+		set.mongo = &MongoSink{DB: db}
+	})
+}
+
+// RegisterAuditSink 注册额外的审计记录写入目标，可在运行时调用
+func RegisterAuditSink(sink AuditSink) {
+	updateAuditSinks(func(set *auditSinkSet) {
+		set.others = append(set.others[:len(set.others):len(set.others)], sink)
+	})
+}
+
+// getAuditSinks 返回全部写入目标
+func getAuditSinks() []AuditSink {
+	set := loadAuditSinks()
+	if set.mongo == nil {
+		return set.others
+	}
+	return append([]AuditSink{set.mongo}, set.others...)
+}
+
+// auditable 只持久化改变状态的操作，未设置方法时同样持久化
+func auditable(method string) bool {
+	return method == "" || (*auditMethods.Load())[strings.ToUpper(method)]
+}
+
+// AuditLog 写入一条操作日志
+func AuditLog(ctx context.Context, op *operation.Model) error {
+	if !auditable(op.Method) {
+		logNotAudited(ctx, op, 1)
+		return nil
+	}
+	sinks := getAuditSinks()
+	if len(sinks) == 0 {
+		return ErrNoAuditSink
+	}
+	prepareOperation(ctx, op)
+	if err := writeAuditSinks(ctx, ctx, sinks, op, 1); err != nil {
+		return err
+	}
+	auditWritten(ctx, op, 1)
+	return nil
+}
+
+// AuditLogInSession 在调用方的事务会话中写入操作日志
+// 业务数据变更与审计记录在同一事务中提交或回滚，
+// 保证不会出现有审计无变更或有变更无审计的情况
+//
+// 事务中只写入 SetAuditDB 设置的数据库，写入失败立即返回错误，调用方应回滚事务
+// 通过 RegisterAuditSink 注册的写入目标无法回滚，由返回的函数在事务提交后写入:
+//
+//	var afterCommit func() error
+//	_, err := sess.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+//		if err := updateOrder(sc, order); err != nil {
+//			return nil, err
+//		}
+//		var err error
+//		afterCommit, err = log.AuditLogInSession(ctx, sc, op)
+//		return nil, err
+//	})
+//	if err == nil {
+//		_ = afterCommit()
+//	}
+//
+// 不需要持久化的操作返回的函数不做任何事
+func AuditLogInSession(ctx context.Context, sess mongo.SessionContext, op *operation.Model) (func() error, error) {
+	if !auditable(op.Method) {
+		logNotAudited(ctx, op, 1)
+		return func() error { return nil }, nil
+	}
+	set := loadAuditSinks()
+	if set.mongo == nil {
+		return nil, ErrNoAuditSink
+	}
+	prepareOperation(ctx, op)
+	if err := writeAuditSinks(ctx, sess, []AuditSink{set.mongo}, op, 1); err != nil {
+		return nil, err
+	}
+	sinks := set.others
+	return func() error {
+		if err := writeAuditSinks(ctx, ctx, sinks, op, 1); err != nil {
+			return err
+		}
+		auditWritten(ctx, op, 1)
+		return nil
+	}, nil
+}
+
+// logNotAudited 按配置输出不持久化的操作
+func logNotAudited(ctx context.Context, op *operation.Model, skip int) {
+	if !auditLogReads.Load() {
+		return
+	}
+	getBaseEntry(ctx, skip+1).
+		WithField(logTypeKey, LogTypeOperation).
+		WithField("method", op.Method).
+		WithField("full_path", op.FullPath).
+		WithField("resp_code", op.RespCode).
+		WithField("operator", op.Operator).
+		Info("operation not audited")
+}
+
+// writeAuditSinks 使用 dbCtx (普通上下文或事务会话) 将操作日志写入 sinks
+// 单个写入目标失败不影响其它写入目标，返回全部错误
+func writeAuditSinks(ctx context.Context, dbCtx context.Context, sinks []AuditSink, op *operation.Model, skip int) error {
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Write(dbCtx, *op); err != nil {
+			auditFailed(ctx, sink, op, err, skip+1)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// auditFields 审计日志行的字段，使用 CEF 格式时映射为 src/request/requestMethod/cn1/suser
+func auditFields(op *operation.Model) logrus.Fields {
+	return logrus.Fields{
+		logTypeKey:  LogTypeOperation,
+		"audit_id":  op.ID.Hex(),
+		"method":    op.Method,
+		"full_path": op.FullPath,
+		"resp_code": op.RespCode,
+		"client_ip": op.ClientIP,
+		"operator":  op.Operator,
+	}
+}
+
+// auditFailed 输出写入失败的审计记录 id 及写入目标
+func auditFailed(ctx context.Context, sink AuditSink, op *operation.Model, err error, skip int) {
+	getBaseEntry(ctx, skip+1).WithError(err).
+		WithFields(auditFields(op)).
+		WithField("sink", fmt.Sprintf("%T", sink)).
+		Error("failed to write audit log")
+}
+
+// auditWritten 写入成功后记录幂等键并输出审计记录 id，便于从日志直接定位到审计文档
+func auditWritten(ctx context.Context, op *operation.Model, skip int) {
+	entry := getBaseEntry(ctx, skip+1).
+		WithFields(auditFields(op)).
+		WithField("collection", op.CollectionName())
+	if op.IdempotencyKey != "" {
+		idempotencyKeys.remember(op.IdempotencyKey)
+		entry = entry.WithField("idempotency_key", op.IdempotencyKey).
+			WithField("duplicate_request", op.DuplicateRequest)
+	}
+	entry.Info("audit log written")
+}
+
+// prepareOperation 填充写入方负责的字段
+func prepareOperation(ctx context.Context, op *operation.Model) {
+	// 写入前生成 id，所有写入目标使用相同的 id
+	if op.ID.IsZero() {
+		op.ID = primitive.NewObjectID()
+	}
+	op.SchemaVersion = operation.CurrentSchemaVersion
+	op.ClientIP = NormalizeIP(op.ClientIP)
+	op.RemoteIP = NormalizeIP(op.RemoteIP)
+	op.IPVersion = IPVersion(op.ClientIP)
+	if op.Timestamp == 0 {
+		op.Timestamp = uint64(time.Now().UnixMilli())
+	}
+	if authenticated, ok := authenticatedFrom(ctx); ok {
+		op.Authenticated = authenticated
+	}
+	if tp, ok := tenantPathFrom(ctx); ok {
+		op.OrgID = tp.org
+		op.StoreID = tp.store
+		if tp.merchant != "" {
+			op.Meta.MerchantID = tp.merchant
+		}
+	}
+	if wf, ok := workflowFrom(ctx); ok {
+		op.WorkflowID = wf.id
+		op.StepName = wf.step
+	}
+	if op.IdempotencyKey == "" {
+		op.IdempotencyKey = idempotencyKeyFrom(ctx)
+	}
+	if op.IdempotencyKey != "" {
+		op.DuplicateRequest = idempotencyKeys.seen(op.IdempotencyKey)
+	}
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// 缓存状态
+const (
+	CacheHit    = "hit"
+	CacheMiss   = "miss"
+	CacheBypass = "bypass"
+)
+
+// cacheStatus 请求期间由处理函数设置的缓存状态
+type cacheStatus struct {
+	status atomic.Pointer[string]
+}
+
+// withCacheStatus 在上下文中创建缓存状态，由 RequestLogger 在请求开始时调用
+func withCacheStatus(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheStatusKey, &cacheStatus{})
+}
+
+// SetCacheStatus 由处理函数设置当前请求的缓存状态(hit/miss/bypass)
+// RequestLogger 会在请求日志中输出为 cache_status 字段
+func SetCacheStatus(ctx context.Context, status string) {
+	if cs, ok := contextOrGLS(ctx).Value(cacheStatusKey).(*cacheStatus); ok {
+		cs.status.Store(&status)
+	}
+}
+
+// cacheStatusFrom 返回处理函数设置的缓存状态
+func cacheStatusFrom(ctx context.Context) string {
+	cs, ok := ctx.Value(cacheStatusKey).(*cacheStatus)
+	if !ok {
+		return ""
+	}
+	if status := cs.status.Load(); status != nil {
+		return *status
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package log
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// callerInfo 调用位置信息
+type callerInfo struct {
+	file string
+	fn   string
+}
+
+// callerDisabled 是否关闭 file/func 字段，零值表示开启
+var callerDisabled atomic.Bool
+
+// SetCallerEnabled 设置是否采集调用位置信息，默认开启
+// 关闭后所有日志都不再调用 runtime.Caller，也不输出 file/func 字段，适用于性能敏感的服务
+func SetCallerEnabled(enabled bool) {
+	callerDisabled.Store(!enabled)
+}
+
+// callerCache 以程序计数器为键缓存调用位置
+// 同一调用点的 pc 固定且调用点数量有限，因此缓存天然有界
+var callerCache sync.Map
+
+// getCallerInfo 返回调用方的 parent/child.go:line 以及函数名
+// skip 为相对于 getCallerInfo 调用者的层级
+func getCallerInfo(skip int) (string, string) {
+	pc, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		panic("Could not get context info for logger!")
+	}
+	if v, ok := callerCache.Load(pc); ok {
+		info := v.(callerInfo)
+		return info.file, info.fn
+	}
+	info := resolveCaller(pc, file, line)
+	callerCache.Store(pc, info)
+	return info.file, info.fn
+}
+
+// resolveCaller 解析调用位置的文件及函数名，结果由 getCallerInfo 缓存
+func resolveCaller(pc uintptr, file string, line int) callerInfo {
+	funcName := runtime.FuncForPC(pc).Name()
+	return callerInfo{
+		file: shortFile(file) + ":" + strconv.Itoa(line),
+		fn:   funcName[strings.LastIndex(funcName, ".")+1:],
+	}
+}
+
+// shortFile 返回 parent/child.go 形式的文件路径，与堆栈共用保持格式一致
+func shortFile(file string) string {
+	// Modify how the filename is extracted to include at least /parent/child.go
+	// Split the file path into its components
+	fileParts := strings.Split(file, "/")
+
+	// Get at least two levels (parent/child.go), or just child.go if less
+	if len(fileParts) > 1 {
+		return strings.Join(fileParts[len(fileParts)-2:], "/")
+	}
+	return fileParts[0]
+}
+
+// internalPrefixes 日志包自身、logrus 以及 runtime 的函数前缀
+// 这些帧对排查问题没有帮助，在堆栈中跳过
+var internalPrefixes = []string{
+	"github.com/open4go/log.",
+	"github.com/sirupsen/logrus.",
+	"runtime.",
+}
+
+// internalFrame 返回函数是否属于需要跳过的帧
+func internalFrame(funcName string) bool {
+	for _, prefix := range internalPrefixes {
+		if strings.HasPrefix(funcName, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package log
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func BenchmarkCaller(b *testing.B) {
+	b.Run("cache", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			getCallerInfo(0)
+		}
+	})
+	b.Run("nocache", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pc, file, line, _ := runtime.Caller(0)
+			resolveCaller(pc, file, line)
+		}
+	})
+}
+
+func TestGetCallerInfo(t *testing.T) {
+	file, fn := getCallerInfo(0)
+	if fn != "TestGetCallerInfo" {
+		t.Errorf("fn = %s", fn)
+	}
+	if !strings.Contains(file, "/caller_test.go:") {
+		t.Errorf("file = %s", file)
+	}
+}
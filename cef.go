@@ -0,0 +1,164 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 日志类型，CEFFormatter 使用 log_type 字段作为 Signature ID
+const (
+	logTypeKey = "log_type"
+	// LogTypeOperation 操作日志(审计日志)
+	LogTypeOperation = "operation"
+	// LogTypeLogin 登录日志
+	LogTypeLogin = "login"
+)
+
+// cefExtensionKeys 将我们的字段映射为 CEF 标准扩展字段
+// 未映射的字段按原名输出，响应代码使用自定义数值字段 cn1
+var cefExtensionKeys = map[string]string{
+	"client_ip":  "src",
+	"remote_ip":  "dst",
+	"full_path":  "request",
+	"method":     "requestMethod",
+	"resp_code":  "cn1",
+	"operator":   "suser",
+	"user_id":    "suid",
+	"account_id": "duid",
+	"device":     "deviceExternalId",
+	"file":       "fname",
+}
+
+// cefExtensionLabels 自定义扩展字段的标签
+var cefExtensionLabels = map[string]string{
+	"cn1": "respCode",
+}
+
+// CEFFormatter 以 CEF (Common Event Format) 格式输出日志
+// 主要用于将操作日志/登录日志直接投递到 SIEM
+// 格式: CEF:Version|Device Vendor|Device Product|Device Version|Signature ID|Name|Severity|Extension
+type CEFFormatter struct {
+	// 厂商，默认 open4go
+	Vendor string
+	// 产品，默认读取 server.name
+	Product string
+	// 产品版本，默认 1.0
+	Version string
+}
+
+// Format 实现 logrus.Formatter
+func (f *CEFFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	vendor := f.Vendor
+	if vendor == "" {
+		vendor = "open4go"
+	}
+	product := f.Product
+	if product == "" {
+		product = viper.GetString("server.name")
+	}
+	version := f.Version
+	if version == "" {
+		version = "1.0"
+	}
+	// 登录/操作日志通过 log_type 区分事件类型
+	signature := "log"
+	if v, ok := entry.Data[logTypeKey]; ok {
+		signature = fmt.Sprint(v)
+	}
+
+	b := &bytes.Buffer{}
+	b.WriteString("CEF:0|")
+	for _, h := range []string{vendor, product, version, signature, entry.Message} {
+		b.WriteString(cefEscapeHeader(h))
+		b.WriteByte('|')
+	}
+	b.WriteString(strconv.Itoa(cefSeverity(entry.Level)))
+	b.WriteByte('|')
+
+	b.WriteString("rt=")
+	b.WriteString(strconv.FormatInt(entry.Time.UnixMilli(), 10))
+
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name, ok := cefExtensionKeys[k]
+		if !ok {
+			name = cefExtensionKey(k)
+		}
+		v := entry.Data[k]
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		b.WriteByte(' ')
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(cefEscapeExtension(fmt.Sprint(v)))
+		if label, ok := cefExtensionLabels[name]; ok {
+			b.WriteString(" " + name + "Label=" + label)
+		}
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// cefSeverity 将日志级别映射为 CEF 严重程度 (0-10)
+func cefSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 10
+	case logrus.ErrorLevel:
+		return 8
+	case logrus.WarnLevel:
+		return 6
+	case logrus.InfoLevel:
+		return 3
+	default:
+		return 1
+	}
+}
+
+var (
+	cefHeaderReplacer    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionReplacer = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefEscapeHeader(s string) string {
+	return cefHeaderReplacer.Replace(s)
+}
+
+func cefEscapeExtension(s string) string {
+	return cefExtensionReplacer.Replace(s)
+}
+
+// cefExtensionKey 扩展字段名只能包含字母、数字及下划线，其它字符替换为下划线
+func cefExtensionKey(k string) string {
+	valid := k != ""
+	for i := 0; i < len(k) && valid; i++ {
+		valid = cefKeyChar(k[i])
+	}
+	if valid {
+		return k
+	}
+	b := []byte(k)
+	for i := range b {
+		if !cefKeyChar(b[i]) {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+func cefKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCEFFormatter(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		logTypeKey:    LogTypeOperation,
+		"resp_code":   500,
+		"bad key=1":   "v",
+		"client_ip":   "203.0.113.7",
+		"order|id|ok": "o-1",
+	})
+	entry.Level = logrus.InfoLevel
+	entry.Message = "audit log written"
+	b, err := (&CEFFormatter{Product: "svc"}).Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	for _, want := range []string{
+		"CEF:0|open4go|svc|1.0|operation|audit log written|3|",
+		" cn1=500 cn1Label=respCode",
+		" bad_key_1=v",
+		" src=203.0.113.7",
+		" order_id_ok=o-1",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in %s", want, out)
+		}
+	}
+	if strings.Contains(out, "outcome=") {
+		t.Errorf("resp_code mapped to outcome: %s", out)
+	}
+}
+
+// memorySink 记录写入的审计记录
+type memorySink struct{ records []AuditRecord }
+
+func (s *memorySink) Write(_ context.Context, record AuditRecord) error {
+	s.records = append(s.records, record)
+	return nil
+}
+
+func TestCEFAuditedOperation(t *testing.T) {
+	buf := captureOutput(t)
+	setFormatter(&safeFormatter{Formatter: &CEFFormatter{Product: "svc"}})
+	sinks := auditSinks.Load()
+	auditSinks.Store(&auditSinkSet{others: []AuditSink{&memorySink{}}})
+	t.Cleanup(func() { auditSinks.Store(sinks) })
+
+	op := &AuditRecord{Method: "POST", FullPath: "/orders", RespCode: 201, ClientIP: "203.0.113.7", Operator: "alice"}
+	if err := AuditLog(context.Background(), op); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"|operation|audit log written|",
+		" requestMethod=POST",
+		" request=/orders",
+		" cn1=201 cn1Label=respCode",
+		" src=203.0.113.7",
+		" suser=alice",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in %s", want, out)
+		}
+	}
+}
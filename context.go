@@ -0,0 +1,84 @@
+package log
+
+import (
+	"context"
+)
+
+// ctxKey 上下文键类型，避免与其它包的字符串键冲突
+type ctxKey string
+
+// 日志读取的标准上下文键，调用方使用 context.WithValue(ctx, log.TraceIDKey, id) 设置
+const (
+	// TraceIDKey trace id
+	TraceIDKey ctxKey = "traceid"
+	// IPKey 请求 ip
+	IPKey ctxKey = "ip"
+	// MerchantKey 商户号
+	MerchantKey ctxKey = "MERCHANT_KEY"
+	// OperatorKey 操作人
+	OperatorKey ctxKey = "OPERATOR_KEY"
+)
+
+// 包内部使用的上下文键
+const (
+	idempotencyKeyKey ctxKey = "IDEMPOTENCY_KEY"
+	authenticatedKey  ctxKey = "AUTHENTICATED_KEY"
+	flagsKey          ctxKey = "FLAGS_KEY"
+	replayKey         ctxKey = "REPLAY_KEY"
+	tenantKey         ctxKey = "TENANT_KEY"
+	cacheStatusKey    ctxKey = "CACHE_STATUS_KEY"
+	workflowKey       ctxKey = "WORKFLOW_KEY"
+)
+
+// legacyKeys 旧版本使用的字符串键，弃用过渡期内仍然读取，新代码请使用对应的类型化键
+var legacyKeys = map[ctxKey]string{
+	TraceIDKey:  "traceid",
+	IPKey:       "ip",
+	MerchantKey: "MERCHANT_KEY",
+	OperatorKey: "OPERATOR_KEY",
+}
+
+// stringFromContext 读取上下文中的字符串值
+// 优先读取类型化键，未设置时回退到旧的字符串键；缺失、非字符串或空字符串均返回 ""
+func stringFromContext(ctx context.Context, key ctxKey) string {
+	if v, ok := ctx.Value(key).(string); ok && v != "" {
+		return v
+	}
+	if legacy, ok := legacyKeys[key]; ok {
+		if v, ok := ctx.Value(legacy).(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// ContextWith 一次性设置日志所需的全部标准上下文字段
+// 适用于 cron、命令行等需要手动构造上下文的入口，空值不会被设置
+func ContextWith(ctx context.Context, trace, ip, merchant, operator string) context.Context {
+	for _, kv := range []struct {
+		key   ctxKey
+		value string
+	}{
+		{TraceIDKey, trace},
+		{IPKey, ip},
+		{MerchantKey, merchant},
+		{OperatorKey, operator},
+	} {
+		if kv.value != "" {
+			ctx = context.WithValue(ctx, kv.key, kv.value)
+		}
+	}
+	return ctx
+}
+
+// WithAuthenticated 标记当前请求是否已通过认证，由认证中间件设置
+// 日志及操作日志会记录为 authenticated 字段
+func WithAuthenticated(ctx context.Context, authenticated bool) context.Context {
+	return context.WithValue(ctx, authenticatedKey, authenticated)
+}
+
+// authenticatedFrom 返回上下文中的认证状态，ok 表示是否设置过
+func authenticatedFrom(ctx context.Context) (authenticated bool, ok bool) {
+	authenticated, ok = ctx.Value(authenticatedKey).(bool)
+	return authenticated, ok
+}
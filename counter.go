@@ -0,0 +1,58 @@
+package log
+
+import (
+	"github.com/gin-gonic/gin"
+	"io"
+)
+
+// countingWriter 统计实际写入响应的字节数
+// 对于 chunked/流式响应同样按实际写入量计算
+type countingWriter struct {
+	gin.ResponseWriter
+	written int64
+}
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *countingWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.written += int64(n)
+	return n, err
+}
+
+// countingReader 统计实际读取的请求体字节数
+// limit 大于 0 时同时保留请求体的前 limit 个字节用于排查问题
+type countingReader struct {
+	io.ReadCloser
+	read    int64
+	limit   int
+	capture []byte
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	if remain := r.limit - len(r.capture); remain > 0 && n > 0 {
+		if n < remain {
+			remain = n
+		}
+		r.capture = append(r.capture, p[:remain]...)
+	}
+	return n, err
+}
+
+// requestBytes 返回请求体大小
+// Content-Length 未知 (chunked) 时使用实际读取的字节数
+func requestBytes(contentLength int64, body *countingReader) int64 {
+	if contentLength >= 0 {
+		return contentLength
+	}
+	if body == nil {
+		return 0
+	}
+	return body.read
+}
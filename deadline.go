@@ -0,0 +1,27 @@
+package log
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// deadlineThreshold 剩余时间低于该值时标记 deadline_imminent，0 表示关闭
+var deadlineThreshold atomic.Int64
+
+// SetDeadlineWarning 设置上下文截止时间的预警阈值，默认关闭
+// 输出日志时若 ctx.Deadline() 剩余时间小于 threshold (例如 50ms)，附加 deadline_imminent=true 字段,
+// 用于发现勉强完成或即将超时的操作，在超时真正发生前调整超时配置；传入 0 关闭
+func SetDeadlineWarning(threshold time.Duration) {
+	deadlineThreshold.Store(int64(threshold))
+}
+
+// deadlineImminent 返回上下文截止时间是否临近
+func deadlineImminent(ctx context.Context) bool {
+	threshold := time.Duration(deadlineThreshold.Load())
+	if threshold <= 0 {
+		return false
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && time.Until(deadline) < threshold
+}
@@ -0,0 +1,30 @@
+//go:build !nolog_debug
+
+package log
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+)
+
+// DebugEnabled 是否编译了 debug 日志，使用 nolog_debug 构建标签时为 false
+// 对于参数构造代价较高的调用可以使用 if log.DebugEnabled { ... } 包裹，nolog_debug 构建时由编译器整体消除
+// 未包裹的 Debug/Debugf 调用在 nolog_debug 构建时为空操作，但参数仍会被求值
+// Log(ctx).Debug、Named(...).Log(ctx).Debug 不受构建标签影响，仍按运行时级别输出
+const DebugEnabled = true
+
+// Debug 输出 debug 日志
+func Debug(ctx context.Context, args ...interface{}) {
+	if !logger.IsLevelEnabled(logrus.DebugLevel) && !replayActive(ctx) {
+		return
+	}
+	getBaseEntry(ctx, 1).Debug(args...)
+}
+
+// Debugf 输出格式化的 debug 日志，同时记录 msg_template
+func Debugf(ctx context.Context, format string, args ...interface{}) {
+	if !logger.IsLevelEnabled(logrus.DebugLevel) && !replayActive(ctx) {
+		return
+	}
+	getBaseEntry(ctx, 1).WithField(msgTemplateKey, format).Debugf(format, args...)
+}
@@ -0,0 +1,20 @@
+//go:build nolog_debug
+
+package log
+
+import (
+	"context"
+)
+
+// DebugEnabled 使用 nolog_debug 构建标签时 Debug/Debugf 为空操作
+// 注意调用处的参数仍会被求值，例如 log.Debugf(ctx, "%v", expensive()) 依然会执行 expensive()，
+// 只有包裹在 if log.DebugEnabled { ... } 中的代码才会被编译器整体消除
+// 构建标签只影响 Debug/Debugf，Log(ctx).Debug、Named(...).Log(ctx).Debug 等通过 logrus 条目输出的 debug 日志
+// 不受影响，仍按运行时级别输出，需要消除时同样使用 if log.DebugEnabled { ... } 包裹
+const DebugEnabled = false
+
+// Debug 空操作，参数仍会被求值
+func Debug(ctx context.Context, args ...interface{}) {}
+
+// Debugf 空操作，参数仍会被求值
+func Debugf(ctx context.Context, format string, args ...interface{}) {}
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// deprecationInterval 同一调用点同一特性的弃用告警最小间隔，单位纳秒
+var deprecationInterval atomic.Int64
+
+func init() {
+	deprecationInterval.Store(int64(time.Hour))
+}
+
+// deprecationSeen 记录每个 特性+调用点 最后一次告警时间(*atomic.Int64，unix 纳秒)
+var deprecationSeen sync.Map
+
+// SetDeprecationInterval 设置弃用告警的限流间隔
+func SetDeprecationInterval(interval time.Duration) {
+	deprecationInterval.Store(int64(interval))
+}
+
+// Deprecated 记录弃用特性的使用情况
+// 同一调用点对同一特性在间隔内只输出一次 warn 日志
+// 用于在移除前统计仍在被调用的弃用接口/函数
+func Deprecated(ctx context.Context, feature string, removeBy string) {
+	file, _ := getCallerInfo(1)
+	key := feature + "@" + file
+
+	v, ok := deprecationSeen.Load(key)
+	if !ok {
+		v, _ = deprecationSeen.LoadOrStore(key, new(atomic.Int64))
+	}
+	seen := v.(*atomic.Int64)
+	now := time.Now().UnixNano()
+	last := seen.Load()
+	if last != 0 && now-last < deprecationInterval.Load() {
+		return
+	}
+	// 并发调用时只有更新成功的协程输出告警
+	if !seen.CompareAndSwap(last, now) {
+		return
+	}
+
+	getBaseEntry(ctx, 1).WithField("deprecation", true).
+		WithField("feature", feature).
+		WithField("remove_by", removeBy).
+		Warning("deprecated feature is still in use")
+}
@@ -0,0 +1,62 @@
+package log
+
+import (
+	"github.com/sirupsen/logrus"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// durationHook 将 time.Duration 类型的字段转换为可读字符串，并增加 _ms 数值字段便于查询
+type durationHook struct {
+	enabled atomic.Bool
+}
+
+var durations = &durationHook{}
+
+var durationsOnce sync.Once
+
+// SetDurationFormatting 开启后 time.Duration 字段输出为 "1.5s" 并额外增加 <key>_ms 字段
+// 默认关闭时 time.Duration 按纳秒整数输出
+func SetDurationFormatting(enabled bool) {
+	durations.enabled.Store(enabled)
+	if enabled {
+		durationsOnce.Do(func() {
+			AddHookWithPriority(durations, PriorityEnrich)
+		})
+	}
+}
+
+// Dur 返回时长字段，包含可读字符串 key 及毫秒数值 key_ms
+//
+//	log.Log(ctx).WithFields(log.Dur("latency", d)).Info("done")
+func Dur(key string, d time.Duration) logrus.Fields {
+	return logrus.Fields{
+		key:         d.String(),
+		key + "_ms": durationMillis(d),
+	}
+}
+
+// durationMillis 返回带小数的毫秒数
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Levels 对所有级别生效
+func (h *durationHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire 转换 time.Duration 字段
+func (h *durationHook) Fire(entry *logrus.Entry) error {
+	if !h.enabled.Load() {
+		return nil
+	}
+	for k, v := range entry.Data {
+		if d, ok := v.(time.Duration); ok {
+			entry.Data[k] = d.String()
+			entry.Data[k+"_ms"] = durationMillis(d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package log
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"os"
+)
+
+// SetExitFunc 设置 Fatal 日志输出后的退出函数，传入 nil 时恢复为 os.Exit
+// 便于进程管理器根据退出码区分异常原因
+func SetExitFunc(fn func(code int)) {
+	if fn == nil {
+		fn = os.Exit
+	}
+	logger.ExitFunc = fn
+}
+
+// RegisterExitHandler 注册退出前执行的清理函数(例如刷新缓冲、关闭连接)
+// 按注册顺序在退出函数之前执行
+func RegisterExitHandler(handler func()) {
+	logrus.RegisterExitHandler(handler)
+}
+
+// FatalWithStack 输出带堆栈的 fatal 日志，执行清理函数后以 code 退出
+func FatalWithStack(ctx context.Context, code int, err error, args ...interface{}) {
+	entry := withErrorDetails(getBaseEntry(ctx, 1), err).
+		WithField("exit_code", code)
+	if len(args) == 0 && err != nil {
+		args = []interface{}{err.Error()}
+	}
+	// Log 在 fatal 级别不会退出，由 Exit 执行清理并以指定退出码退出
+	entry.Log(logrus.FatalLevel, args...)
+	logger.Exit(code)
+}
@@ -0,0 +1,39 @@
+package log
+
+import (
+	"context"
+	"time"
+)
+
+// AttemptResult 外部调用的一次尝试结果
+type AttemptResult struct {
+	// 响应状态码，未收到响应时为 0
+	Status int
+	// 耗时
+	Duration time.Duration
+	// 错误
+	Err error
+}
+
+// LogExternalCallFailure 外部调用重试后仍然失败时输出一条汇总的错误日志
+// attempts 字段按顺序记录每次尝试的状态码、耗时及错误，比逐次输出更便于排查不稳定的下游服务
+func LogExternalCallFailure(ctx context.Context, service, endpoint string, attempts []AttemptResult, finalErr error) {
+	history := make([]map[string]interface{}, len(attempts))
+	for i, a := range attempts {
+		attempt := map[string]interface{}{
+			"attempt":     i + 1,
+			"status":      a.Status,
+			"duration_ms": a.Duration.Milliseconds(),
+		}
+		if a.Err != nil {
+			attempt["error"] = a.Err.Error()
+		}
+		history[i] = attempt
+	}
+	withErrorDetails(getBaseEntry(ctx, 1), finalErr).
+		WithField("service", service).
+		WithField("endpoint", endpoint).
+		WithField("attempts", history).
+		WithField("attempt_count", len(attempts)).
+		Error("external call failed")
+}
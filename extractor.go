@@ -0,0 +1,64 @@
+package log
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"sync/atomic"
+)
+
+// ContextExtractor 从上下文中提取日志字段
+type ContextExtractor func(ctx context.Context) logrus.Fields
+
+var (
+	// extractorsMu 串行化注册
+	extractorsMu sync.Mutex
+	// extractors 每次注册生成新的切片，读取时无需加锁
+	extractors atomic.Pointer[[]ContextExtractor]
+)
+
+// RegisterContextExtractor 注册上下文字段提取函数，每次 Log/ErrorWithStack 等调用都会执行
+// 用于输出服务自定义的上下文信息(租户、会话、语言等)，无需修改本包；需在程序启动时注册
+func RegisterContextExtractor(fn ContextExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	var current []ContextExtractor
+	if p := extractors.Load(); p != nil {
+		current = *p
+	}
+	chain := make([]ContextExtractor, len(current), len(current)+1)
+	copy(chain, current)
+	chain = append(chain, fn)
+	extractors.Store(&chain)
+}
+
+// RegisterContextField 将上下文中 key 对应的值输出为 fieldName 字段，值为空时不输出
+// key 应使用服务自定义的类型，避免与其它包冲突，例如:
+//
+//	type sessionKey struct{}
+//
+//	log.RegisterContextField(sessionKey{}, "session_id")
+//	ctx = context.WithValue(ctx, sessionKey{}, "s-123")
+func RegisterContextField(key any, fieldName string) {
+	RegisterContextExtractor(func(ctx context.Context) logrus.Fields {
+		v := ctx.Value(key)
+		if v == nil || v == "" {
+			return nil
+		}
+		return logrus.Fields{fieldName: v}
+	})
+}
+
+// withExtractedFields 执行全部提取函数并附加字段
+func withExtractedFields(ctx context.Context, entry *logrus.Entry) *logrus.Entry {
+	p := extractors.Load()
+	if p == nil {
+		return entry
+	}
+	for _, fn := range *p {
+		if fields := fn(ctx); len(fields) > 0 {
+			entry = entry.WithFields(fields)
+		}
+	}
+	return entry
+}
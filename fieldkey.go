@@ -0,0 +1,76 @@
+package log
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"hash/fnv"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultMaxFieldKeyLength 字段名的默认最大长度
+const defaultMaxFieldKeyLength = 128
+
+// fieldKeyHashLength 截断字段名后附加的 ~ 及 8 位十六进制哈希的长度
+const fieldKeyHashLength = 9
+
+// fieldKeyHook 截断过长的字段名，避免破坏日志索引
+type fieldKeyHook struct {
+	maxLength atomic.Int64
+	warned    atomic.Bool
+}
+
+var fieldKeys = &fieldKeyHook{}
+
+var fieldKeyOnce sync.Once
+
+// SetMaxFieldKeyLength 设置字段名的最大长度并开启截断，超出部分会被截断
+// 截断后的字段名以 ~ 加原字段名的哈希结尾，不同的长字段名不会互相覆盖
+// 第一次发生截断时向标准错误输出一条警告，传入 0 或负数时使用默认值 128
+func SetMaxFieldKeyLength(n int) {
+	if n <= 0 {
+		n = defaultMaxFieldKeyLength
+	}
+	fieldKeys.maxLength.Store(int64(n))
+	fieldKeyOnce.Do(func() {
+		// 先于脱敏执行，保证后续 hook 看到的都是截断后的字段名
+		AddHookWithPriority(fieldKeys, PriorityRedact-1)
+	})
+}
+
+// Levels 对所有级别生效
+func (h *fieldKeyHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire 截断过长的字段名
+func (h *fieldKeyHook) Fire(entry *logrus.Entry) error {
+	maxLength := int(h.maxLength.Load())
+	truncated := 0
+	for k, v := range entry.Data {
+		if len(k) <= maxLength {
+			continue
+		}
+		delete(entry.Data, k)
+		entry.Data[truncateFieldKey(k, maxLength)] = v
+		truncated++
+	}
+	// 直接写标准错误，在 hook 中再次记录日志会重入 hook 链
+	if truncated > 0 && h.warned.CompareAndSwap(false, true) {
+		fmt.Fprintf(os.Stderr, "log field keys exceeded max length %d and were truncated (%d keys), check the caller\n",
+			maxLength, truncated)
+	}
+	return nil
+}
+
+// truncateFieldKey 截断字段名并附加原字段名的哈希
+// 最大长度不足以容纳哈希时只截断
+func truncateFieldKey(key string, maxLength int) string {
+	if maxLength <= fieldKeyHashLength {
+		return key[:maxLength]
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return fmt.Sprintf("%s~%08x", key[:maxLength-fieldKeyHashLength], h.Sum32())
+}
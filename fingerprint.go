@@ -0,0 +1,136 @@
+package log
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultFingerprintFrames 默认参与计算指纹的堆栈帧数
+	defaultFingerprintFrames = 3
+	// maxLimitedFingerprints 限流状态最多保留的指纹数量
+	maxLimitedFingerprints = 10000
+	// fingerprintKey 错误指纹字段
+	fingerprintKey = "fingerprint"
+	// rateLimitedKey 标记被限流的错误日志，主输出、输出目标及上报均跳过
+	rateLimitedKey = "_rate_limited"
+)
+
+// fingerprintFrames 参与计算指纹的堆栈帧数
+var fingerprintFrames atomic.Int32
+
+func init() {
+	fingerprintFrames.Store(defaultFingerprintFrames)
+}
+
+// SetFingerprintFrames 设置计算错误指纹时使用的栈顶帧数，默认 3，小于等于 0 时恢复默认值
+func SetFingerprintFrames(n int) {
+	if n <= 0 {
+		n = defaultFingerprintFrames
+	}
+	fingerprintFrames.Store(int32(n))
+}
+
+// fingerprint 计算错误日志的指纹
+// ErrorWithStack 等输出的日志使用 fingerprint 字段(错误类型+栈顶帧)，
+// 其它日志按错误类型及信息计算，用于聚合统计
+func fingerprint(entry *logrus.Entry) string {
+	if fp, ok := entry.Data[fingerprintKey].(string); ok {
+		return fp
+	}
+	h := fnv.New64a()
+	if err, ok := entry.Data[logrus.ErrorKey].(error); ok {
+		fmt.Fprintf(h, "%T:%s", err, err.Error())
+	} else {
+		h.Write([]byte(entry.Message))
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// errorFingerprint 根据错误类型及栈顶帧计算指纹
+// 不包含错误信息，信息中带有订单号等变量的同一错误拥有相同的指纹
+func errorFingerprint(err error, frames []StackFrame) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%T", err)
+	n := int(fingerprintFrames.Load())
+	for i := 0; i < n && i < len(frames); i++ {
+		fmt.Fprintf(h, "|%s:%s", frames[i].Func, frames[i].File)
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// withFingerprint 附加错误指纹并判断是否限流
+func withFingerprint(entry *logrus.Entry, err error, frames []StackFrame) *logrus.Entry {
+	fp := errorFingerprint(err, frames)
+	entry = entry.WithField(fingerprintKey, fp)
+	limited, suppressed := errorLimiter.allow(fp, time.Now())
+	if limited {
+		return entry.WithField(rateLimitedKey, true)
+	}
+	if suppressed > 0 {
+		// 上一个周期内被限流的次数
+		entry = entry.WithField("suppressed_count", suppressed)
+	}
+	return entry
+}
+
+// rateLimited 判断错误日志是否被限流
+func rateLimited(entry *logrus.Entry) bool {
+	_, ok := entry.Data[rateLimitedKey]
+	return ok
+}
+
+// errorRateLimiter 按指纹限流，每个周期内只输出第一次出现的错误
+type errorRateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	seen     map[string]*limitState
+}
+
+// limitState 单个指纹的限流状态
+type limitState struct {
+	last       time.Time
+	suppressed int
+}
+
+var errorLimiter = &errorRateLimiter{}
+
+// SetErrorRateLimit 开启按错误指纹限流，传入 0 关闭
+// 同一指纹的错误第一次出现时输出，之后每个 interval 内最多输出一次，
+// 再次输出时附加 suppressed_count 字段记录期间被限流的次数
+func SetErrorRateLimit(interval time.Duration) {
+	errorLimiter.mu.Lock()
+	defer errorLimiter.mu.Unlock()
+	errorLimiter.interval = interval
+	errorLimiter.seen = make(map[string]*limitState)
+}
+
+// allow 返回是否限流，以及不限流时此前被限流的次数
+func (l *errorRateLimiter) allow(fp string, now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.interval <= 0 {
+		return false, 0
+	}
+	state, ok := l.seen[fp]
+	if !ok {
+		if len(l.seen) >= maxLimitedFingerprints {
+			l.seen = make(map[string]*limitState)
+		}
+		l.seen[fp] = &limitState{last: now}
+		return false, 0
+	}
+	if now.Sub(state.last) < l.interval {
+		state.suppressed++
+		return true, 0
+	}
+	suppressed := state.suppressed
+	state.last = now
+	state.suppressed = 0
+	return false, suppressed
+}
@@ -0,0 +1,61 @@
+package log
+
+import (
+	"context"
+	"sync"
+)
+
+// maxFlags 每个请求最多记录的特性开关数量
+const maxFlags = 32
+
+// flagSet 请求期间评估过的特性开关
+type flagSet struct {
+	mu    sync.Mutex
+	flags map[string]interface{}
+}
+
+// withFlagSet 在上下文中创建特性开关集合，由 RequestLogger 在请求开始时调用
+func withFlagSet(ctx context.Context) context.Context {
+	return context.WithValue(ctx, flagsKey, &flagSet{flags: make(map[string]interface{})})
+}
+
+// LogFlag 记录一次特性开关的评估结果
+// 输出一条 debug 日志，并累计到请求的完成日志的 flags 字段中(每个请求最多 maxFlags 个)
+func LogFlag(ctx context.Context, flag string, value interface{}, reason string) {
+	ctx = contextOrGLS(ctx)
+	if set, ok := ctx.Value(flagsKey).(*flagSet); ok {
+		set.add(flag, value)
+	}
+	getBaseEntry(ctx, 1).
+		WithField("flag", flag).
+		WithField("flag_value", value).
+		WithField("flag_reason", reason).
+		Debug("feature flag evaluated")
+}
+
+func (s *flagSet) add(flag string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.flags[flag]; !ok && len(s.flags) >= maxFlags {
+		return
+	}
+	s.flags[flag] = value
+}
+
+// flagsFrom 返回上下文中累计的特性开关，没有时返回 nil
+func flagsFrom(ctx context.Context) map[string]interface{} {
+	set, ok := ctx.Value(flagsKey).(*flagSet)
+	if !ok {
+		return nil
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if len(set.flags) == 0 {
+		return nil
+	}
+	flags := make(map[string]interface{}, len(set.flags))
+	for k, v := range set.flags {
+		flags[k] = v
+	}
+	return flags
+}
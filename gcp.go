@@ -0,0 +1,77 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"os"
+	"time"
+)
+
+// gcpTraceKey Cloud Logging 识别的 trace 字段
+const gcpTraceKey = "logging.googleapis.com/trace"
+
+// GCPFormatter 输出 Google Cloud Logging 可以直接解析的 JSON
+// 使用 severity/message 字段，并将 trace 转换为 projects/<project>/traces/<trace> 格式
+type GCPFormatter struct {
+	// GCP 项目 id，为空时读取 gcp.project 配置或 GOOGLE_CLOUD_PROJECT 环境变量
+	ProjectID string
+}
+
+// Format 实现 logrus.Formatter
+func (f *GCPFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(entry.Data)+3)
+	for k, v := range entry.Data {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		data[k] = v
+	}
+
+	if trace, ok := data["trace"]; ok {
+		delete(data, "trace")
+		if project := f.projectID(); project != "" {
+			data[gcpTraceKey] = fmt.Sprintf("projects/%s/traces/%v", project, trace)
+		} else {
+			data[gcpTraceKey] = trace
+		}
+	}
+	data["severity"] = gcpSeverity(entry.Level)
+	data["message"] = entry.Message
+	data["time"] = entry.Time.Format(time.RFC3339Nano)
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fields to JSON, %w", err)
+	}
+	return append(b, '\n'), nil
+}
+
+func (f *GCPFormatter) projectID() string {
+	if f.ProjectID != "" {
+		return f.ProjectID
+	}
+	if project := viper.GetString("gcp.project"); project != "" {
+		return project
+	}
+	return os.Getenv("GOOGLE_CLOUD_PROJECT")
+}
+
+// gcpSeverity 将日志级别映射为 Cloud Logging severity
+func gcpSeverity(level logrus.Level) string {
+	switch level {
+	case logrus.PanicLevel:
+		return "ALERT"
+	case logrus.FatalLevel:
+		return "CRITICAL"
+	case logrus.ErrorLevel:
+		return "ERROR"
+	case logrus.WarnLevel:
+		return "WARNING"
+	case logrus.InfoLevel:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
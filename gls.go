@@ -0,0 +1,69 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// 协程本地上下文(goroutine-local storage)
+//
+// 仅用于难以逐层传递 ctx 的遗留代码，使用前必须调用 EnableGLS(true) 显式开启。
+// 注意事项:
+//   - Go 没有官方的协程本地存储，这里通过解析 runtime.Stack 获取协程 id，每次查找都有额外开销
+//   - 新启动的协程不会继承父协程的上下文，需要在新协程中重新调用 SetGLSContext
+//   - 协程结束前必须调用 ClearGLSContext，否则会造成内存泄漏（建议 defer 调用）
+//   - 新代码请始终显式传递 ctx
+
+// glsEnabled 是否开启协程本地上下文
+var glsEnabled atomic.Bool
+
+// glsContexts 协程 id 到上下文的映射
+var glsContexts sync.Map
+
+// EnableGLS 开启或关闭协程本地上下文，默认关闭
+func EnableGLS(enabled bool) {
+	glsEnabled.Store(enabled)
+}
+
+// SetGLSContext 设置当前协程的日志上下文
+// 开启后 Log(nil) 等调用会使用该上下文
+func SetGLSContext(ctx context.Context) {
+	if !glsEnabled.Load() {
+		return
+	}
+	glsContexts.Store(goroutineID(), ctx)
+}
+
+// ClearGLSContext 清除当前协程的日志上下文
+func ClearGLSContext() {
+	glsContexts.Delete(goroutineID())
+}
+
+// contextOrGLS 当 ctx 为 nil 时回退到协程本地上下文
+func contextOrGLS(ctx context.Context) context.Context {
+	if ctx != nil {
+		return ctx
+	}
+	if glsEnabled.Load() {
+		if v, ok := glsContexts.Load(goroutineID()); ok {
+			return v.(context.Context)
+		}
+	}
+	return context.Background()
+}
+
+// goroutineID 从协程栈信息 "goroutine 123 [running]:" 中解析协程 id
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// processStart 进程启动时间，用于计算运行时长
+var processStart = time.Now()
+
+// defaultHeartbeatInterval 默认的心跳间隔
+const defaultHeartbeatInterval = time.Minute
+
+// StartHeartbeat 周期性输出心跳日志，包含运行时长、协程数量及堆内存
+// 用于在基于日志的监控中区分 "没有流量" 和 "进程挂起"，interval 小于等于 0 时使用默认值 1 分钟
+// 返回的函数用于在关闭时停止心跳
+func StartHeartbeat(interval time.Duration) func() {
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				var m runtime.MemStats
+				runtime.ReadMemStats(&m)
+				getBaseEntry(context.Background(), 0).
+					WithField("heartbeat", true).
+					WithField("uptime", time.Since(processStart).Round(time.Second).String()).
+					WithField("goroutines", runtime.NumGoroutine()).
+					WithField("heap_alloc_mb", float64(m.HeapAlloc)/1024/1024).
+					Info("heartbeat")
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package log
+
+import (
+	"github.com/sirupsen/logrus"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// hook 优先级，数值越小越先执行
+const (
+	// PriorityRedact 脱敏 hook，必须先于持久化和统计执行，避免敏感数据被写入或计数
+	PriorityRedact = 0
+	// PriorityEnrich 补充字段的 hook
+	PriorityEnrich = 50
+	// PriorityDefault 默认优先级
+	PriorityDefault = 100
+	// PriorityPersist 持久化/外发 hook，最后执行以使用已脱敏和补充后的字段
+	PriorityPersist = 200
+)
+
+// prioritizedHook 带优先级的 hook
+type prioritizedHook struct {
+	hook     logrus.Hook
+	priority int
+	levels   map[logrus.Level]bool
+}
+
+// hookChain 按优先级依次执行 hook
+// logrus 按注册顺序执行 hook，无法控制先后，因此所有 hook 统一由 hookChain 调度
+type hookChain struct {
+	mu    sync.RWMutex
+	hooks []prioritizedHook
+}
+
+var hooks = &hookChain{}
+
+var hooksOnce sync.Once
+
+// AddHook 以默认优先级注册 hook，在 Init 之后调用
+func AddHook(hook logrus.Hook) {
+	AddHookWithPriority(hook, PriorityDefault)
+}
+
+// AddHookWithPriority 注册 hook，数值越小越先执行，相同优先级按注册顺序执行
+// 脱敏类 hook 应使用 PriorityRedact，持久化类 hook 应使用 PriorityPersist
+func AddHookWithPriority(hook logrus.Hook, priority int) {
+	levels := make(map[logrus.Level]bool)
+	for _, level := range hook.Levels() {
+		levels[level] = true
+	}
+
+	// 每次注册生成新的切片，Fire 中持有的旧切片不受影响
+	hooks.mu.Lock()
+	chain := make([]prioritizedHook, len(hooks.hooks), len(hooks.hooks)+1)
+	copy(chain, hooks.hooks)
+	chain = append(chain, prioritizedHook{hook: hook, priority: priority, levels: levels})
+	sort.SliceStable(chain, func(i, j int) bool {
+		return chain[i].priority < chain[j].priority
+	})
+	hooks.hooks = chain
+	hooks.mu.Unlock()
+
+	hooksOnce.Do(func() {
+		logger.AddHook(hooks)
+	})
+}
+
+// removeHook 移除通过 AddHook/AddHookWithPriority 注册的 hook，hook 应为指针
+func removeHook(hook logrus.Hook) {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	chain := make([]prioritizedHook, 0, len(hooks.hooks))
+	for _, h := range hooks.hooks {
+		// 不可比较的 hook 类型不会与指针相等，跳过比较避免 panic
+		if !reflect.TypeOf(h.hook).Comparable() || h.hook != hook {
+			chain = append(chain, h)
+		}
+	}
+	hooks.hooks = chain
+}
+
+// Levels 对所有级别生效，具体级别由各 hook 自行声明
+func (c *hookChain) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire 按优先级执行 hook，某个 hook 出错不影响后续 hook，返回第一个错误
+func (c *hookChain) Fire(entry *logrus.Entry) error {
+	// 复制后释放锁，避免 hook 内部再次输出日志时死锁
+	c.mu.RLock()
+	chain := c.hooks
+	c.mu.RUnlock()
+
+	var firstErr error
+	for _, h := range chain {
+		if !h.levels[entry.Level] {
+			continue
+		}
+		if err := h.hook.Fire(entry); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,142 @@
+package log
+
+import (
+	"bufio"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// statusWriter 记录 net/http 响应的状态码及写入字节数
+type statusWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush 支持流式响应
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 支持 websocket 等接管连接的处理器
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// ReadFrom 保留底层 writer 的 sendfile 等优化
+func (w *statusWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, r)
+	}
+	w.written += n
+	return n, err
+}
+
+// Unwrap 供 http.ResponseController 访问底层 writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// HTTPRequestLogger 与 RequestLogger 相同的 net/http 中间件，用于未使用 gin 的服务
+// 支持相同的选项，route 字段为空
+//
+//	http.ListenAndServe(":8080", log.HTTPRequestLogger(mux, log.WithRequestLog(log.RequestLogCombined)))
+func HTTPRequestLogger(next http.Handler, opts ...MiddlewareOption) http.Handler {
+	cfg := newMiddlewareConfig(opts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+
+		ctx, traceID := requestContext(r.Context(), r.Header, httpClientIP(r, cfg), cfg)
+		r = r.WithContext(ctx)
+
+		requestFields := logrus.Fields{
+			"method":     r.Method,
+			"path":       r.URL.Path,
+			"user_agent": r.UserAgent(),
+		}
+		if cfg.logMode == RequestLogStartEnd {
+			Log(ctx).WithFields(requestFields).Info("request started")
+		}
+
+		var body *countingReader
+		if r.Body != nil {
+			body = &countingReader{ReadCloser: r.Body, limit: cfg.captureLimit()}
+			r.Body = body
+		}
+		writer := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(writer, r)
+
+		status := writer.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		finishRequest(ctx, cfg, requestResult{
+			request:    r,
+			fields:     requestFields,
+			traceID:    traceID,
+			status:     status,
+			duration:   time.Since(startTime),
+			reqBytes:   requestBytes(r.ContentLength, body),
+			respBytes:  writer.written,
+			respHeader: writer.Header(),
+			body:       body,
+		})
+	})
+}
+
+// httpClientIP 返回连接的远端地址
+// 远端为 WithTrustedProxies 设置的代理时优先使用 X-Forwarded-For/X-Real-Ip 中的客户端地址
+func httpClientIP(r *http.Request, cfg *middlewareConfig) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if remote := net.ParseIP(host); remote == nil || !cfg.trustedProxy(remote) {
+		return host
+	}
+	if ip := ClientIPFromForwarded(r.Header.Get("X-Forwarded-For")); ip != "" {
+		return ip
+	}
+	if ip := r.Header.Get("X-Real-Ip"); ip != "" {
+		return ip
+	}
+	return host
+}
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestHTTPClientIPTrustedProxies(t *testing.T) {
+	cfg := newMiddlewareConfig([]MiddlewareOption{WithTrustedProxies("10.0.0.0/8", "192.168.1.1")})
+	for _, tc := range []struct {
+		remote, xff, want string
+	}{
+		{"10.1.2.3:1234", "203.0.113.7", "203.0.113.7"},
+		{"192.168.1.1:1234", "203.0.113.7", "203.0.113.7"},
+		{"198.51.100.1:1234", "203.0.113.7", "198.51.100.1"},
+		{"10.1.2.3:1234", "", "10.1.2.3"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tc.remote
+		if tc.xff != "" {
+			r.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		if got := httpClientIP(r, cfg); got != tc.want {
+			t.Errorf("remote %s xff %q: got %s, want %s", tc.remote, tc.xff, got, tc.want)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.1.2.3:1234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if got := httpClientIP(r, newMiddlewareConfig(nil)); got != "10.1.2.3" {
+		t.Errorf("headers trusted without trusted proxies: %s", got)
+	}
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestStatusWriterPassthrough(t *testing.T) {
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w := &statusWriter{ResponseWriter: rec}
+
+	var rw http.ResponseWriter = w
+	if _, ok := rw.(http.Flusher); !ok {
+		t.Error("statusWriter is not a Flusher")
+	}
+	if _, _, err := rw.(http.Hijacker).Hijack(); err != nil || !rec.hijacked {
+		t.Errorf("hijack not passed through: %v", err)
+	}
+
+	w = &statusWriter{ResponseWriter: httptest.NewRecorder()}
+	n, err := w.ReadFrom(strings.NewReader("hello"))
+	if err != nil || n != 5 || w.written != 5 || w.status != http.StatusOK {
+		t.Errorf("ReadFrom: n=%d written=%d status=%d err=%v", n, w.written, w.status, err)
+	}
+}
+
+func TestErrorDetailBodyRedacted(t *testing.T) {
+	buf := captureOutput(t)
+	handler := HTTPRequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusBadRequest)
+	}), WithErrorDetail(1024))
+
+	send := func() {
+		r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"user":"u1","password":"secret-pw"}`))
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	// 未注册脱敏规则时不记录请求体
+	if !redactionConfigured() {
+		send()
+		if strings.Contains(buf.String(), "secret-pw") || strings.Contains(buf.String(), `"body"`) {
+			t.Errorf("body logged without redaction: %s", buf.String())
+		}
+		buf.Reset()
+	}
+
+	RegisterRedactor(Redactor{Fields: []string{"password"}})
+	send()
+	out := buf.String()
+	if strings.Contains(out, "secret-pw") {
+		t.Errorf("password leaked: %s", out)
+	}
+	if !strings.Contains(out, `"user":"u1"`) {
+		t.Errorf("body not logged: %s", out)
+	}
+}
+
+func TestStatusLevelMap(t *testing.T) {
+	defer SetStatusLevelMap(map[int]logrus.Level{2: logrus.InfoLevel, 3: logrus.InfoLevel, 4: logrus.WarnLevel, 5: logrus.ErrorLevel})
+	SetStatusLevelMap(map[int]logrus.Level{404: logrus.DebugLevel, 4: logrus.ErrorLevel})
+	if statusLevel(404) != logrus.DebugLevel || statusLevel(400) != logrus.ErrorLevel || statusLevel(200) != logrus.InfoLevel {
+		t.Errorf("unexpected levels: %v %v %v", statusLevel(404), statusLevel(400), statusLevel(200))
+	}
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// idempotencyTTL 幂等键在内存中保留的时间
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyMaxKeys 内存中最多保留的幂等键数量
+	idempotencyMaxKeys = 10000
+)
+
+// idempotencyTracker 在内存中记录近期出现过的幂等键，用于标记重复请求
+// 仅在单实例内有效，多实例部署时只能发现落在同一实例上的重复请求
+type idempotencyTracker struct {
+	mu   sync.Mutex
+	keys map[string]time.Time
+}
+
+var idempotencyKeys = &idempotencyTracker{keys: make(map[string]time.Time)}
+
+// WithIdempotencyKey 在上下文中设置幂等键，操作日志会记录为 idempotency_key
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey, key)
+}
+
+// idempotencyKeyFrom 返回上下文中的幂等键
+func idempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyKey).(string)
+	return key
+}
+
+// seen 返回幂等键此前是否出现过
+func (t *idempotencyTracker) seen(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, ok := t.keys[key]
+	return ok && time.Since(last) < idempotencyTTL
+}
+
+// remember 记录幂等键，在审计记录写入成功后调用
+// 避免写入失败重试时被误判为重复请求
+func (t *idempotencyTracker) remember(key string) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.keys[key]; !ok && len(t.keys) >= idempotencyMaxKeys {
+		t.evict(now)
+	}
+	t.keys[key] = now
+}
+
+// evict 清理过期的幂等键，仍然超出上限时清空
+func (t *idempotencyTracker) evict(now time.Time) {
+	for k, at := range t.keys {
+		if now.Sub(at) >= idempotencyTTL {
+			delete(t.keys, k)
+		}
+	}
+	if len(t.keys) >= idempotencyMaxKeys {
+		t.keys = make(map[string]time.Time)
+	}
+}
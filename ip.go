@@ -0,0 +1,72 @@
+package log
+
+import (
+	"net"
+	"strings"
+)
+
+// NormalizeIP 规范化 IP 地址
+// 去除端口、IPv6 方括号，将 ::ffff: 映射地址还原为 IPv4
+// 无法解析时原样返回(去除首尾空白)
+func NormalizeIP(raw string) string {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return ""
+	}
+	// X-Forwarded-For 链
+	if strings.Contains(s, ",") {
+		return ClientIPFromForwarded(s)
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	// 去除 IPv6 zone，例如 fe80::1%eth0
+	if i := strings.IndexByte(s, '%'); i >= 0 {
+		s = s[:i]
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return s
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String()
+	}
+	return ip.String()
+}
+
+// IPVersion 返回 IP 地址版本 4 或 6，无法解析时返回 0
+func IPVersion(ip string) int {
+	parsed := net.ParseIP(NormalizeIP(ip))
+	if parsed == nil {
+		return 0
+	}
+	if parsed.To4() != nil {
+		return 4
+	}
+	return 6
+}
+
+// ClientIPFromForwarded 从 X-Forwarded-For 链中选取客户端地址
+// 从右往左跳过内网/回环地址(我们自己的代理)，返回第一个公网地址
+// 如果全部为内网地址则返回最左侧的合法地址
+func ClientIPFromForwarded(xff string) string {
+	hops := strings.Split(xff, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := NormalizeIP(hops[i])
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			continue
+		}
+		if !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() {
+			return hop
+		}
+	}
+	for _, h := range hops {
+		hop := NormalizeIP(h)
+		if net.ParseIP(hop) != nil {
+			return hop
+		}
+	}
+	return ""
+}
@@ -0,0 +1,211 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// levels 支持的日志级别
+var levels = map[string]logrus.Level{
+	"debug": logrus.DebugLevel,
+	"info":  logrus.InfoLevel,
+	"warn":  logrus.WarnLevel,
+	"error": logrus.ErrorLevel,
+}
+
+// setLevel 设置日志级别，供 Init 使用，未知级别使用 info
+func setLevel(logLevel string) {
+	// test 保持当前级别
+	if logLevel == "test" {
+		return
+	}
+	level, ok := levels[logLevel]
+	if !ok {
+		level = logrus.InfoLevel
+	}
+	applyLevel(level)
+}
+
+var (
+	// sinkLevel 输出目标中最低的级别，未增加输出目标时为 panic
+	sinkLevel atomic.Uint32
+	// levelMu 串行化主输出及输出目标级别的修改，保证 logrus 实例的级别与两者一致
+	levelMu sync.Mutex
+)
+
+// applyLevel 设置主输出的日志级别
+// logrus 使用原子操作读写级别，可以在其它协程输出日志时并发调用
+func applyLevel(level logrus.Level) {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	outputLevel.Store(uint32(level))
+	logger.SetLevel(loggerLevel(level))
+}
+
+// lowerSinkLevel 增加输出目标时按需降低 logrus 实例的级别
+func lowerSinkLevel(level logrus.Level) {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	if uint32(level) > sinkLevel.Load() {
+		sinkLevel.Store(uint32(level))
+	}
+	logger.SetLevel(loggerLevel(logrus.Level(outputLevel.Load())))
+}
+
+// loggerLevel 返回主输出级别为 level 时 logrus 实例的级别
+// logrus 按主输出与输出目标中较低的级别创建日志，主输出由 safeFormatter 按 level 过滤，输出目标按各自的级别过滤
+func loggerLevel(level logrus.Level) logrus.Level {
+	if s := logrus.Level(sinkLevel.Load()); s > level {
+		return s
+	}
+	return level
+}
+
+// SetLevel 运行时调整日志级别，无需重启
+// 支持: debug, info, warn, error，未知级别返回错误
+func SetLevel(logLevel string) error {
+	level, ok := levels[logLevel]
+	if !ok {
+		return fmt.Errorf("unknown log level: %s", logLevel)
+	}
+	applyLevel(level)
+	return nil
+}
+
+// GetLevel 返回当前日志级别
+func GetLevel() string {
+	return levelName(logrus.Level(outputLevel.Load()))
+}
+
+// levelName 返回级别在配置中使用的名称
+func levelName(level logrus.Level) string {
+	for name, l := range levels {
+		if l == level {
+			return name
+		}
+	}
+	return level.String()
+}
+
+// WatchLevel 监听配置文件变化，log.level 修改后实时生效
+// 注意 viper 只保留最后一次注册的 OnConfigChange 回调
+func WatchLevel() {
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		// 模块级别 log.levels.<name>
+		refreshNamedLevels()
+		logLevel := viper.GetString("log.level")
+		if logLevel == "" || logLevel == GetLevel() {
+			return
+		}
+		if err := SetLevel(logLevel); err != nil {
+			logger.WithError(err).Warn("failed to apply log level from config")
+			return
+		}
+		logger.WithField("log_level", logLevel).Info("log level changed")
+	})
+	viper.WatchConfig()
+}
+
+var (
+	// levelRevertMu 保护临时级别的恢复定时器
+	levelRevertMu sync.Mutex
+	levelRevert   *time.Timer
+	// levelBaseline 临时级别到期后恢复的级别
+	levelBaseline string
+)
+
+// levelRequest LevelHandler 的请求及响应
+type levelRequest struct {
+	Level string `json:"level"`
+	// 临时生效的时长，例如 15m，到期后恢复为修改前的级别
+	Duration string `json:"duration,omitempty"`
+}
+
+// LevelHandler 返回查看及修改日志级别的 HTTP 处理函数，应挂载在仅内部可访问的管理端口上
+//
+//	GET  返回 {"level":"info"}
+//	PUT  /log/level?level=debug&duration=15m 或 body {"level":"debug","duration":"15m"}
+//
+// 指定 duration 时到期后自动恢复为修改前的级别，便于排查线上问题时临时打开 debug
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			req := levelRequest{
+				Level:    r.URL.Query().Get("level"),
+				Duration: r.URL.Query().Get("duration"),
+			}
+			if req.Level == "" && r.Body != nil {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			if err := changeLevel(req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(levelRequest{Level: GetLevel()})
+	})
+}
+
+// changeLevel 修改日志级别，指定时长时到期后恢复
+func changeLevel(req levelRequest) error {
+	var d time.Duration
+	if req.Duration != "" {
+		var err error
+		if d, err = time.ParseDuration(req.Duration); err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration: %s", req.Duration)
+		}
+	}
+
+	levelRevertMu.Lock()
+	defer levelRevertMu.Unlock()
+	previous := GetLevel()
+	if levelRevert != nil {
+		// 临时级别生效期间再次修改，到期后仍恢复为最初的级别
+		previous = levelBaseline
+	}
+	if err := SetLevel(req.Level); err != nil {
+		return err
+	}
+	// 新的修改覆盖之前的临时级别
+	if levelRevert != nil {
+		levelRevert.Stop()
+		levelRevert = nil
+	}
+	entry := logger.WithField("log_level", req.Level).WithField("previous", previous)
+	if d > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(d, func() {
+			levelRevertMu.Lock()
+			defer levelRevertMu.Unlock()
+			// 已被新的修改取代
+			if levelRevert != timer {
+				return
+			}
+			levelRevert = nil
+			_ = SetLevel(previous)
+			logger.WithField("log_level", previous).Info("temporary log level expired")
+		})
+		levelRevert = timer
+		levelBaseline = previous
+		entry = entry.WithField("duration", d.String())
+	}
+	entry.Info("log level changed")
+	return nil
+}
@@ -0,0 +1,85 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// captureOutput 将日志输出到缓冲区，测试结束后恢复
+func captureOutput(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	out, f, level := currentOutput(), logger.Formatter, GetLevel()
+	setOutput(&buf)
+	setFormatter(&safeFormatter{Formatter: &logrus.JSONFormatter{}})
+	t.Cleanup(func() {
+		setOutput(out)
+		setFormatter(f)
+		_ = SetLevel(level)
+	})
+	return &buf
+}
+
+func TestSetLevelAtRuntime(t *testing.T) {
+	for _, replay := range []bool{false, true} {
+		buf := captureOutput(t)
+		replayEnabled.Store(replay)
+		if err := SetLevel("info"); err != nil {
+			t.Fatal(err)
+		}
+		Log(context.Background()).Debug("before change")
+		if strings.Contains(buf.String(), "before change") {
+			t.Errorf("replay=%v: debug emitted at info level", replay)
+		}
+		if GetLevel() != "info" {
+			t.Errorf("replay=%v: GetLevel = %s", replay, GetLevel())
+		}
+
+		if err := SetLevel("debug"); err != nil {
+			t.Fatal(err)
+		}
+		Log(context.Background()).Debug("after change")
+		if !strings.Contains(buf.String(), "after change") {
+			t.Errorf("replay=%v: debug not emitted after SetLevel(debug)", replay)
+		}
+		if GetLevel() != "debug" {
+			t.Errorf("replay=%v: GetLevel = %s", replay, GetLevel())
+		}
+		replayEnabled.Store(false)
+	}
+}
+
+func TestSinkBelowOutputLevel(t *testing.T) {
+	buf := captureOutput(t)
+	if err := SetLevel("info"); err != nil {
+		t.Fatal(err)
+	}
+	var sinkBuf bytes.Buffer
+	prev := sinkLevel.Load()
+	h := addSink(&sinkBuf, logrus.DebugLevel, &logrus.JSONFormatter{})
+	t.Cleanup(func() {
+		removeHook(h)
+		sinkLevel.Store(prev)
+	})
+
+	ctx := context.Background()
+	Log(ctx).Debug("global debug")
+	Named("sink-test").Log(ctx).Debug("module debug")
+	Log(ctx).Info("global info")
+
+	for _, msg := range []string{"global debug", "module debug", "global info"} {
+		if !strings.Contains(sinkBuf.String(), msg) {
+			t.Errorf("sink missing %q: %s", msg, sinkBuf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "debug") {
+		t.Errorf("main output got debug logs: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "global info") {
+		t.Errorf("main output missing info log: %s", buf.String())
+	}
+}
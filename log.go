@@ -1,15 +1,16 @@
 package log
 
 import (
+	"fmt"
 	"github.com/docker/docker/client"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 	"io"
 	"os"
-	"runtime"
-	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // getDockerMetadata fetches the Docker container metadata
@@ -31,89 +32,178 @@ func getDockerMetadata() (string, string, string, error) {
 
 var logger = logrus.New()
 
+// output 全局日志当前的输出，全局日志与模块日志通过同一把锁写入
+var output atomic.Pointer[lockedWriter]
+
+func init() {
+	setOutput(logger.Out)
+	setFormatter(&safeFormatter{Formatter: logger.Formatter})
+}
+
+// lockedWriter 串行化多个 logrus 实例对同一输出的写入
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// setOutput 设置全局日志的输出
+func setOutput(w io.Writer) {
+	lw := &lockedWriter{w: w}
+	output.Store(lw)
+	logger.SetOutput(lw)
+}
+
+// formatter 全局日志当前的格式，模块日志通过它读取
+var formatter atomic.Pointer[logrus.Formatter]
+
+// setFormatter 设置全局日志的格式
+func setFormatter(f logrus.Formatter) {
+	formatter.Store(&f)
+	logger.SetFormatter(f)
+}
+
+// currentOutput 返回全局日志当前的输出
+func currentOutput() io.Writer {
+	return output.Load().w
+}
+
+// Config 日志配置
+type Config struct {
+	// 日志级别 debug/info/warn/error
+	Level string
+	// 日志格式 json/text/cef/gcp，为空时读取 log.format 配置，默认 json
+	Format string
+}
+
 // Init 在main函数中必须初始化
 func Init(logLevel string, output io.Writer) {
+	if err := InitWithOptions(Config{Level: logLevel}, output); err != nil {
+		// 格式配置错误时回退到json格式
+		setFormatter(&safeFormatter{Formatter: &logrus.JSONFormatter{}})
+		logger.WithError(err).Warn("invalid log format, fallback to json")
+	}
+}
+
+// InitWithOptions 使用配置初始化日志
+// 本地开发可以使用 text 格式，线上使用 json 格式，也可以通过 log.format 配置按环境切换
+func InitWithOptions(cfg Config, output io.Writer) error {
 	if output != nil {
-		logger.SetOutput(output)
+		setOutput(output)
 	} else {
 		// 输出到终端
-		logger.SetOutput(os.Stdout)
-	}
-	// 强制使用json日志格式
-	logger.SetFormatter(&logrus.JSONFormatter{})
-	// 设置日志级别
-	switch logLevel {
-	case "debug":
-		logger.SetLevel(logrus.DebugLevel)
-	case "test":
-	case "info":
-		logger.SetLevel(logrus.InfoLevel)
-	case "warn":
-		logger.SetLevel(logrus.WarnLevel)
-	case "error":
-		logger.SetLevel(logrus.ErrorLevel)
-	default:
-		logger.SetLevel(logrus.InfoLevel)
+		setOutput(os.Stdout)
+	}
+	setLevel(cfg.Level)
+
+	format := cfg.Format
+	if format == "" {
+		format = viper.GetString("log.format")
+	}
+	if format == "" {
+		// 默认使用json日志格式
+		format = "json"
 	}
+	return SetFormat(format)
 }
 
-func Log(ctx context.Context) *logrus.Entry {
-	pc, file, line, ok := runtime.Caller(1)
+// formatters 支持的日志格式
+var formatters = map[string]func() logrus.Formatter{
+	"json": func() logrus.Formatter { return &logrus.JSONFormatter{} },
+	"text": func() logrus.Formatter {
+		return &logrus.TextFormatter{FullTimestamp: true, TimestampFormat: time.RFC3339}
+	},
+	"cef": func() logrus.Formatter { return &CEFFormatter{} },
+	"gcp": func() logrus.Formatter { return &GCPFormatter{} },
+}
+
+// SetFormat 切换日志输出格式，在 Init 之后调用
+// 支持: json (默认), text (本地开发), cef (用于安全审计日志投递到 SIEM), gcp (Google Cloud Logging)
+func SetFormat(format string) error {
+	newFormatter, ok := formatters[format]
 	if !ok {
-		panic("Could not get context info for logger!")
+		return fmt.Errorf("unknown log format: %s", format)
 	}
+	setFormatter(&safeFormatter{Formatter: newFormatter()})
+	return nil
+}
 
-	serverName := viper.GetString("server.name")
+// Log 返回携带上下文信息的日志条目
+func Log(ctx context.Context) *logrus.Entry {
+	return getBaseEntry(ctx, 1)
+}
 
-	// 拼接必要字段
-	//filename := file[strings.LastIndex(file, "/")+1:] + ":" + strconv.Itoa(line)
-	// Modify how the filename is extracted to include at least /parent/child.go
-	// Split the file path into its components
-	fileParts := strings.Split(file, "/")
+// getBaseEntry 构建基础日志条目
+// skip 为相对于 getBaseEntry 调用者需要跳过的层级，用于定位真正的调用位置
+func getBaseEntry(ctx context.Context, skip int) *logrus.Entry {
+	return buildEntry(staticBaseEntry(), ctx, skip+1)
+}
 
-	// Get at least two levels (parent/child.go), or just child.go if less
-	var filename string
-	if len(fileParts) > 1 {
-		filename = strings.Join(fileParts[len(fileParts)-2:], "/") + ":" + strconv.Itoa(line)
-	} else {
-		filename = fileParts[0] + ":" + strconv.Itoa(line)
+// buildEntry 在 base 的基础上附加调用位置及上下文字段
+func buildEntry(base *logrus.Entry, ctx context.Context, skip int) *logrus.Entry {
+	// ctx 为 nil 时回退到协程本地上下文
+	ctx = contextOrGLS(ctx)
+	logCtx := base.WithContext(ctx)
+	replayEntry(logCtx, ctx)
+	// 关闭调用位置信息时跳过 runtime.Caller
+	if !callerDisabled.Load() {
+		filename, fn := getCallerInfo(skip + 1)
+		logCtx = logCtx.
+			WithField("file", filename).
+			WithField("func", fn)
 	}
-
-	funcName := runtime.FuncForPC(pc).Name()
-	fn := funcName[strings.LastIndex(funcName, ".")+1:]
-
-	logCtx := logger.
-		WithField("file", filename).
-		WithField("func", fn).
-		WithField("server", serverName)
 	// 增加traceid
 	// 部分情况下无法获取到
-	traceID := ctx.Value("traceid")
+	traceID := stringFromContext(ctx, TraceIDKey)
+	if traceID == "" {
+		traceID = missingTraceID()
+	}
 	if traceID != "" {
 		logCtx = logCtx.WithField("trace", traceID)
 	}
 	// 增加请求ip
-	ip := ctx.Value("ip")
-	if ip != "" {
-		logCtx = logCtx.WithField("ip", ip)
+	if ip := NormalizeIP(stringFromContext(ctx, IPKey)); ip != "" {
+		logCtx = logCtx.WithField("ip", ip).
+			WithField("ip_version", IPVersion(ip))
 	}
-	// 获取镜像元数据
-	image, container, instanceID, err := getDockerMetadata()
-	if err != nil {
-		//log.Printf("Failed to get Docker metadata (when run it on local, can ignore this) %v", err)
-		return logCtx
-	} else {
-		// 只有容器中运行才能获取到相关信息
-		// 并且运行的容器需要挂着配置 /var/run/docker.sock
-		// 例如:
-		// services:
-		//  member:
-		//    image: r2day/member-api:pro
-		//    volumes:
-		//      - /var/run/docker.sock:/var/run/docker.sock
-		return logCtx.
-			WithField("image", image).
-			WithField("container", container).
-			WithField("instance", instanceID)
+	// 增加商户号及操作人
+	if merchant := stringFromContext(ctx, MerchantKey); merchant != "" {
+		logCtx = logCtx.WithField("merchantId", merchant)
+	}
+	if operator := stringFromContext(ctx, OperatorKey); operator != "" {
+		logCtx = logCtx.WithField("operator", operator)
+	}
+	// 增加租户层级
+	if tp, ok := tenantPathFrom(ctx); ok {
+		fields := logrus.Fields{}
+		for k, v := range map[string]string{"org_id": tp.org, "merchant_id": tp.merchant, "store_id": tp.store} {
+			if v != "" {
+				fields[k] = v
+			}
+		}
+		logCtx = logCtx.WithFields(fields)
+	}
+	// 增加工作流
+	if wf, ok := workflowFrom(ctx); ok {
+		logCtx = logCtx.WithField("workflow_id", wf.id)
+		if wf.step != "" {
+			logCtx = logCtx.WithField("step_name", wf.step)
+		}
+	}
+	// 增加认证状态
+	if authenticated, ok := authenticatedFrom(ctx); ok {
+		logCtx = logCtx.WithField("authenticated", authenticated)
+	}
+	// 服务注册的上下文字段
+	logCtx = withExtractedFields(ctx, logCtx)
+	// 即将超时
+	if deadlineImminent(ctx) {
+		logCtx = logCtx.WithField("deadline_imminent", true)
 	}
+	return logCtx
 }
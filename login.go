@@ -0,0 +1,101 @@
+package log
+
+import (
+	"context"
+	"github.com/open4go/log/model/login"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// GeoResolver 根据 IP 解析地理位置，可以接入 MaxMind 等实现
+type GeoResolver interface {
+	Resolve(ip string) (country string, city string, err error)
+}
+
+// geoResolver 地理位置解析器，未设置时不解析
+var geoResolver atomic.Pointer[GeoResolver]
+
+// SetGeoResolver 设置登录日志使用的地理位置解析器
+func SetGeoResolver(r GeoResolver) {
+	if r == nil {
+		geoResolver.Store(nil)
+		return
+	}
+	geoResolver.Store(&r)
+}
+
+// EnrichLogin 使用客户端 IP 和 user agent 补充登录日志的设备及地理位置信息，未设置登录时间时使用当前时间
+// 未设置日志类型时使用 LogTypeLogin，用于反欺诈分析，地理位置解析失败时忽略
+func EnrichLogin(m *login.Model, ip string, ua string) {
+	if m.LogType == "" {
+		m.LogType = LogTypeLogin
+	}
+	m.ClientIP = NormalizeIP(ip)
+	m.UserAgent = ua
+	m.DeviceType = deviceType(ua)
+	if m.Timestamp == 0 {
+		m.Timestamp = uint64(time.Now().UnixMilli())
+	}
+
+	resolver := geoResolver.Load()
+	if resolver == nil || m.ClientIP == "" {
+		return
+	}
+	country, city, err := (*resolver).Resolve(m.ClientIP)
+	if err != nil {
+		return
+	}
+	m.GeoCountry = country
+	m.GeoCity = city
+}
+
+// EnrichLoginContext 使用上下文中的租户层级及工作流补充登录日志
+func EnrichLoginContext(ctx context.Context, m *login.Model) {
+	if tp, ok := tenantPathFrom(ctx); ok {
+		m.OrgID = tp.org
+		m.StoreID = tp.store
+		if tp.merchant != "" {
+			m.Meta.MerchantID = tp.merchant
+		}
+	}
+	if wf, ok := workflowFrom(ctx); ok {
+		m.WorkflowID = wf.id
+		m.StepName = wf.step
+	}
+}
+
+// LoginLog 输出一条登录事件日志，log_type 为登录日志的类型，使用 CEF 格式时作为 Signature ID
+// 写入登录日志表后调用，用于将登录事件投递到 SIEM
+func LoginLog(ctx context.Context, m *login.Model) {
+	logType := m.LogType
+	if logType == "" {
+		logType = LogTypeLogin
+	}
+	getBaseEntry(ctx, 1).
+		WithField(logTypeKey, logType).
+		WithField("login_id", m.ID.Hex()).
+		WithField("user_id", m.UserID).
+		WithField("account_id", m.AccountID).
+		WithField("client_ip", m.ClientIP).
+		WithField("device_type", m.DeviceType).
+		WithField("resp_code", m.RespCode).
+		Info("login")
+}
+
+// deviceType 根据 user agent 粗略判断设备类型
+func deviceType(ua string) string {
+	s := strings.ToLower(ua)
+	switch {
+	case s == "":
+		return ""
+	case strings.Contains(s, "bot") || strings.Contains(s, "spider") || strings.Contains(s, "crawler"):
+		return "bot"
+	case strings.Contains(s, "ipad") || strings.Contains(s, "tablet"):
+		return "tablet"
+	case strings.Contains(s, "mobile") || strings.Contains(s, "iphone") || strings.Contains(s, "android"):
+		return "mobile"
+	default:
+		return "desktop"
+	}
+}
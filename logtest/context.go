@@ -0,0 +1,68 @@
+package logtest
+
+import (
+	"context"
+	"github.com/open4go/log"
+)
+
+// 测试上下文的默认值
+const (
+	DefaultTrace    = "test-trace"
+	DefaultIP       = "127.0.0.1"
+	DefaultMerchant = "test-merchant"
+	DefaultOperator = "test-operator"
+)
+
+// contextValues 测试上下文字段
+type contextValues struct {
+	trace    string
+	ip       string
+	merchant string
+	operator string
+}
+
+// Option 修改测试上下文的字段
+type Option func(*contextValues)
+
+// WithTrace 设置 trace id
+func WithTrace(trace string) Option {
+	return func(v *contextValues) {
+		v.trace = trace
+	}
+}
+
+// WithIP 设置请求 ip
+func WithIP(ip string) Option {
+	return func(v *contextValues) {
+		v.ip = ip
+	}
+}
+
+// WithMerchant 设置商户号
+func WithMerchant(merchant string) Option {
+	return func(v *contextValues) {
+		v.merchant = merchant
+	}
+}
+
+// WithOperator 设置操作人
+func WithOperator(operator string) Option {
+	return func(v *contextValues) {
+		v.operator = operator
+	}
+}
+
+// TestContext 返回已填充 trace/ip/merchant/operator 的上下文
+// 字段默认使用固定值，便于在测试中断言日志字段
+func TestContext(opts ...Option) context.Context {
+	v := &contextValues{
+		trace:    DefaultTrace,
+		ip:       DefaultIP,
+		merchant: DefaultMerchant,
+		operator: DefaultOperator,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return log.ContextWith(context.Background(), v.trace, v.ip, v.merchant, v.operator)
+}
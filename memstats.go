@@ -0,0 +1,63 @@
+package log
+
+import (
+	"github.com/sirupsen/logrus"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// memStatsInterval ReadMemStats 会短暂 STW，因此最多每隔该时间采样一次
+const memStatsInterval = time.Second
+
+// memStatsHook 在错误日志中附加内存及 GC 信息
+type memStatsHook struct {
+	enabled atomic.Bool
+
+	mu         sync.Mutex
+	sampledAt  time.Time
+	heapAllocM float64
+	numGC      uint32
+}
+
+var memStats = &memStatsHook{}
+
+var memStatsOnce sync.Once
+
+// SetMemStatsOnError 设置 error 及以上级别日志是否附加 heap_alloc_mb 和 num_gc 字段
+// 内存数据按 memStatsInterval 采样，用于排查接近 OOM 时发生的错误
+func SetMemStatsOnError(enabled bool) {
+	memStats.enabled.Store(enabled)
+	if enabled {
+		memStatsOnce.Do(func() {
+			AddHookWithPriority(memStats, PriorityEnrich)
+		})
+	}
+}
+
+// Levels 仅对 error 及以上级别生效
+func (h *memStatsHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+// Fire 附加最近一次采样的内存信息
+func (h *memStatsHook) Fire(entry *logrus.Entry) error {
+	if !h.enabled.Load() {
+		return nil
+	}
+	h.mu.Lock()
+	if time.Since(h.sampledAt) >= memStatsInterval {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		h.heapAllocM = float64(m.HeapAlloc) / 1024 / 1024
+		h.numGC = m.NumGC
+		h.sampledAt = time.Now()
+	}
+	heapAllocM, numGC := h.heapAllocM, h.numGC
+	h.mu.Unlock()
+
+	entry.Data["heap_alloc_mb"] = heapAllocM
+	entry.Data["num_gc"] = numGC
+	return nil
+}
@@ -0,0 +1,54 @@
+package log
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// messageFieldsHook 将指定字段追加到日志信息中
+// 用于只索引 msg 而不解析 JSON 字段的日志管道
+type messageFieldsHook struct {
+	fields atomic.Pointer[[]string]
+}
+
+var messageFields = &messageFieldsHook{}
+
+var messageFieldsOnce sync.Once
+
+// SetMessageFields 设置需要追加到 msg 中的字段，例如 msg="request failed trace=abc operator=joe"
+// 字段仍然保留为结构化字段，传入空切片时关闭
+func SetMessageFields(fields []string) {
+	copied := append([]string(nil), fields...)
+	messageFields.fields.Store(&copied)
+	messageFieldsOnce.Do(func() {
+		// 在脱敏之后执行，追加的值已经过脱敏
+		AddHookWithPriority(messageFields, PriorityEnrich)
+	})
+}
+
+// Levels 对所有级别生效
+func (h *messageFieldsHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire 追加字段到日志信息
+func (h *messageFieldsHook) Fire(entry *logrus.Entry) error {
+	fields := h.fields.Load()
+	if fields == nil || len(*fields) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString(entry.Message)
+	for _, k := range *fields {
+		v, ok := entry.Data[k]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, " %s=%v", k, v)
+	}
+	entry.Message = b.String()
+	return nil
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+const (
+	// maxBuildMetadata 构建元数据最多的字段数量
+	maxBuildMetadata = 16
+	// maxBuildMetadataValue 构建元数据值的最大长度
+	maxBuildMetadataValue = 256
+)
+
+// buildMetadata 附加到每条日志的构建元数据
+var buildMetadata atomic.Pointer[logrus.Fields]
+
+// SetBuildMetadata 设置附加到每条日志的构建元数据(例如 CI 流水线 id、构建者)
+// 为避免日志膨胀最多 maxBuildMetadata 个字段，超长的值会被截断
+func SetBuildMetadata(meta map[string]string) error {
+	if len(meta) > maxBuildMetadata {
+		return fmt.Errorf("too many build metadata fields: %d > %d", len(meta), maxBuildMetadata)
+	}
+	fields := make(logrus.Fields, len(meta))
+	for k, v := range meta {
+		if len(v) > maxBuildMetadataValue {
+			// 在字符边界截断，避免产生非法的 UTF-8
+			cut := maxBuildMetadataValue
+			for cut > 0 && !utf8.RuneStart(v[cut]) {
+				cut--
+			}
+			v = v[:cut]
+		}
+		fields[k] = v
+	}
+	buildMetadata.Store(&fields)
+	resetStaticEntry()
+	return nil
+}
+
+// withBuildMetadata 附加构建元数据
+func withBuildMetadata(entry *logrus.Entry) *logrus.Entry {
+	fields := buildMetadata.Load()
+	if fields == nil || len(*fields) == 0 {
+		return entry
+	}
+	return entry.WithFields(*fields)
+}
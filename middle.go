@@ -1,57 +1,440 @@
 package log
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// Request log modes
+const (
+	// RequestLogNone only logs slow requests (default)
+	RequestLogNone = iota
+	// RequestLogCombined logs one line per request when the response completes
+	RequestLogCombined
+	// RequestLogStartEnd logs a line when the request starts and another when it completes
+	RequestLogStartEnd
+)
+
+// sensitiveHeaders are never included in the error detail log
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// statusLevels maps a response status to the level of the request log line.
+// Keys are either an exact status code (e.g. 404) or a status class (e.g. 4 for 4xx),
+// exact codes take precedence.
+var statusLevels atomic.Pointer[map[int]logrus.Level]
+
+func init() {
+	SetStatusLevelMap(map[int]logrus.Level{
+		2: logrus.InfoLevel,
+		3: logrus.InfoLevel,
+		4: logrus.WarnLevel,
+		5: logrus.ErrorLevel,
+	})
+}
+
+// SetStatusLevelMap replaces the status to level mapping of the request log line,
+// safe to call while requests are being handled
+func SetStatusLevelMap(levels map[int]logrus.Level) {
+	m := make(map[int]logrus.Level, len(levels))
+	for status, level := range levels {
+		m[status] = level
+	}
+	statusLevels.Store(&m)
+}
+
+// statusLevel returns the log level for a response status, info when unmapped
+func statusLevel(status int) logrus.Level {
+	levels := *statusLevels.Load()
+	if level, ok := levels[status]; ok {
+		return level
+	}
+	if level, ok := levels[status/100]; ok {
+		return level
+	}
+	return logrus.InfoLevel
+}
+
+// middlewareConfig holds the options of RequestLogger
+type middlewareConfig struct {
+	// log every request, lean line for success and detailed line for errors
+	errorDetail bool
+	// max bytes of request body kept for the error detail log, only used when redaction is configured
+	bodyLimit int
+	// one of RequestLogNone, RequestLogCombined, RequestLogStartEnd
+	logMode int
+	// replay buffered low level logs when the request fails
+	verboseReplay bool
+	// response header carrying the cache status, empty to only use SetCacheStatus
+	cacheHeader string
+	// request headers carrying the trace and caller identity
+	headers HeaderNames
+	// write an operation log for mutating requests
+	operationLog bool
+	// proxies whose X-Forwarded-For/X-Real-Ip headers are trusted by HTTPRequestLogger
+	trustedProxies []*net.IPNet
+}
+
+// HeaderNames names the request headers the middleware reads the trace and caller identity from,
+// an empty name disables that header
+type HeaderNames struct {
+	Trace    string
+	Operator string
+	Merchant string
+	User     string
+	Device   string
+}
+
+// DefaultHeaderNames are the request headers read by the middleware unless WithHeaderNames is used
+var DefaultHeaderNames = HeaderNames{
+	Trace:    "X-Trace-ID",
+	Operator: "X-Operator",
+	Merchant: "X-Merchant-ID",
+	User:     "X-User-ID",
+	Device:   "X-Device-ID",
+}
+
+// MiddlewareOption configures RequestLogger
+type MiddlewareOption func(*middlewareConfig)
+
+// WithErrorDetail logs every request: successful responses get a lean summary line,
+// while 4xx/5xx responses are logged with request headers.
+// The first bodyLimit bytes of the request body are logged too, but only once redaction rules are registered
+// through RegisterRedactor/RegisterValuePattern, so that credentials in bodies do not leak into the logs;
+// a bodyLimit of 0 never logs the body
+func WithErrorDetail(bodyLimit int) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.errorDetail = true
+		cfg.bodyLimit = bodyLimit
+	}
+}
+
+// WithRequestLog sets how every request is logged,
+// either a single combined completion line or separate start and end lines
+func WithRequestLog(mode int) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.logMode = mode
+	}
+}
+
+// WithVerboseReplay buffers the logs below the configured level (e.g. debug) during a request
+// and emits them at their original level only when the request ends in an error (5xx or errors recorded by handlers),
+// giving full debug context for failing requests with minimal steady-state volume
+func WithVerboseReplay() MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.verboseReplay = true
+	}
+}
+
+// WithCacheStatusHeader reads the cache status (hit/miss/bypass) from the given response header (e.g. X-Cache-Status)
+// when the handler did not set it with SetCacheStatus
+func WithCacheStatusHeader(name string) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.cacheHeader = name
+	}
+}
+
+// WithHeaderNames overrides the request headers the trace, operator, merchant, user and device are read from
+func WithHeaderNames(headers HeaderNames) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.headers = headers
+	}
+}
+
+// WithOperationLog writes an operation log (operation.Model) for every auditable request (POST/PUT/PATCH/DELETE by default, see SetAuditMethods)
+// with the path, method, response code, device and user id taken from the request,
+// written asynchronously when StartOperationWriter is running, otherwise to the sinks set with SetAuditDB/RegisterAuditSink
+func WithOperationLog() MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.operationLog = true
+	}
+}
+
+// WithTrustedProxies sets the proxies, as CIDRs or single IPs, allowed to set the client IP of HTTPRequestLogger
+// through X-Forwarded-For/X-Real-Ip; requests from any other address are logged with the connection's remote address.
+// Without this option the headers are never trusted. RequestLogger uses gin's own trusted proxy settings
+func WithTrustedProxies(proxies ...string) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		for _, proxy := range proxies {
+			if !strings.Contains(proxy, "/") {
+				if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+					proxy += "/32"
+				} else {
+					proxy += "/128"
+				}
+			}
+			_, network, err := net.ParseCIDR(proxy)
+			if err != nil {
+				logger.WithError(err).WithField("proxy", proxy).Warn("invalid trusted proxy")
+				continue
+			}
+			cfg.trustedProxies = append(cfg.trustedProxies, network)
+		}
+	}
+}
+
+// captureLimit returns how many bytes of the request body to keep for the error detail log
+func (cfg *middlewareConfig) captureLimit() int {
+	if !cfg.errorDetail || !redactionConfigured() {
+		return 0
+	}
+	return cfg.bodyLimit
+}
+
+// trustedProxy reports whether the remote address belongs to a trusted proxy
+func (cfg *middlewareConfig) trustedProxy(ip net.IP) bool {
+	for _, network := range cfg.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// newMiddlewareConfig applies the options over the defaults
+func newMiddlewareConfig(opts []MiddlewareOption) *middlewareConfig {
+	cfg := &middlewareConfig{headers: DefaultHeaderNames}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.verboseReplay {
+		enableReplay()
+	}
+	return cfg
+}
+
 // RequestLogger logs the request time and other relevant details
-func RequestLogger() gin.HandlerFunc {
+func RequestLogger(opts ...MiddlewareOption) gin.HandlerFunc {
+	cfg := newMiddlewareConfig(opts)
+
 	return func(c *gin.Context) {
 		startTime := time.Now()
 
-		// Process the request
-		c.Next()
-
-		// Calculate request duration
-		duration := time.Since(startTime)
+		// Add trace ID, IP, and other fields to the context
+		ctx, traceID := requestContext(c.Request.Context(), c.Request.Header, c.ClientIP(), cfg)
+		c.Request = c.Request.WithContext(ctx)
 
-		// Get request details
+		// Capture request details at start, they are held until the response completes
 		path := c.Request.URL.Path
 		method := c.Request.Method
-		statusCode := c.Writer.Status()
+		requestFields := logrus.Fields{
+			"method":     method,
+			"path":       path,
+			"user_agent": c.Request.UserAgent(),
+		}
+		if cfg.logMode == RequestLogStartEnd {
+			Log(ctx).WithFields(requestFields).Info("request started")
+		}
 
-		// Add trace ID, IP, and other fields to the context
-		ctx := c.Request.Context()
-		traceID := ctx.Value("traceid")
-		if traceID == "" {
-			traceID = c.GetHeader("X-Trace-ID") // Assuming trace ID comes from header
-			ctx = context.WithValue(ctx, "traceid", traceID)
+		// Count request and response bytes
+		var body *countingReader
+		if c.Request.Body != nil {
+			body = &countingReader{ReadCloser: c.Request.Body, limit: cfg.captureLimit()}
+			c.Request.Body = body
 		}
+		writer := &countingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
 
-		ip := c.ClientIP()
+		// Process the request
+		c.Next()
 
-		// Attach context values for trace ID and IP
-		ctx = context.WithValue(ctx, "ip", ip)
+		result := requestResult{
+			request: c.Request,
+			fields:  requestFields,
+			traceID: traceID,
+			status:  c.Writer.Status(),
+			// Matched route pattern (e.g. /orders/:id) keeps cardinality low, empty when no route matched
+			route:      c.FullPath(),
+			duration:   time.Since(startTime),
+			reqBytes:   requestBytes(c.Request.ContentLength, body),
+			respBytes:  writer.written,
+			respHeader: c.Writer.Header(),
+			body:       body,
+		}
+		if len(c.Errors) > 0 {
+			// Errors recorded by handlers carry the stacktrace
+			result.err = c.Errors.Last().Err
+			result.errors = c.Errors.String()
+		}
+		finishRequest(ctx, cfg, result)
+	}
+}
+
+// requestResult is the outcome of a request handled by the middleware
+type requestResult struct {
+	request    *http.Request
+	fields     logrus.Fields
+	traceID    string
+	status     int
+	route      string
+	duration   time.Duration
+	reqBytes   int64
+	respBytes  int64
+	respHeader http.Header
+	body       *countingReader
+	// last error recorded by the handler and all recorded errors
+	err    error
+	errors string
+}
 
-		currentLatency := duration.Milliseconds()
-		maxLatency := viper.GetInt64("server.maxLatency")
+// finishRequest replays buffered logs, logs slow requests, writes the operation log and logs the completion line
+func finishRequest(ctx context.Context, cfg *middlewareConfig, r requestResult) {
+	statusCode := r.status
+	if rb := replayFrom(ctx); rb != nil && (statusCode >= 500 || r.err != nil) {
+		rb.flush()
+	}
+
+	currentLatency := r.duration.Milliseconds()
+	maxLatency := viper.GetInt64("server.maxLatency")
+
+	// 如果一个请求时间超过设定的最大时长则应该认为是异常情况
+	// 因此打印输出日志便于排查问题
+	if currentLatency > maxLatency {
+		// Log the request details with the custom logger
+		Log(ctx).WithFields(logrus.Fields{
+			"method":      r.fields["method"],
+			"path":        r.fields["path"],
+			"route":       r.route,
+			"trace":       r.traceID,
+			"status":      statusCode,
+			"max_latency": maxLatency,
+			"latency":     currentLatency,
+			"req_bytes":   r.reqBytes,
+			"resp_bytes":  r.respBytes,
+		}).Warning("current request has reached latency")
+	}
 
-		// 如果一个请求时间超过设定的最大时长则应该认为是异常情况
-		// 因此打印输出日志便于排查问题
-		if currentLatency > maxLatency {
-			// Log the request details with the custom logger
-			Log(ctx).WithFields(logrus.Fields{
-				"method":      method,
-				"path":        path,
-				"trace":       ctx.Value("traceid"),
-				"status":      statusCode,
-				"max_latency": maxLatency,
-				"latency":     duration.Milliseconds(),
-			}).Warning("current request has reached latency")
+	if cfg.operationLog {
+		recordRequestOperation(ctx, cfg, r.request, r.route, statusCode, r.reqBytes, r.respBytes)
+	}
+
+	if !cfg.errorDetail && cfg.logMode == RequestLogNone {
+		return
+	}
+	entry := Log(ctx).WithFields(r.fields).WithFields(logrus.Fields{
+		"route":      r.route,
+		"status":     statusCode,
+		"latency":    currentLatency,
+		"req_bytes":  r.reqBytes,
+		"resp_bytes": r.respBytes,
+	})
+	cache := cacheStatusFrom(ctx)
+	if cache == "" && cfg.cacheHeader != "" {
+		cache = r.respHeader.Get(cfg.cacheHeader)
+	}
+	if cache != "" {
+		entry = entry.WithField("cache_status", cache)
+	}
+	if flags := flagsFrom(ctx); flags != nil {
+		entry = entry.WithField("flags", flags)
+	}
+	level := statusLevel(statusCode)
+	if statusCode >= 500 && r.err != nil {
+		entry = withErrorDetails(entry, r.err).
+			WithField("errors", r.errors)
+	}
+	if !cfg.errorDetail || statusCode < 400 {
+		entry.Log(level, "request completed")
+		return
+	}
+	// Error responses carry extra detail for diagnosis
+	entry = entry.WithField("headers", safeHeaders(r.request.Header))
+	if r.body != nil && len(r.body.capture) > 0 {
+		entry = entry.WithField("body", capturedBody(r.body.capture))
+	}
+	entry.Log(level, "request failed")
+}
+
+// capturedBody decodes a JSON body so the redaction rules also match nested field names,
+// other or truncated bodies are logged as a string
+func capturedBody(b []byte) interface{} {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err == nil && !dec.More() {
+		if _, ok := v.(string); !ok {
+			return v
+		}
+	}
+	return string(b)
+}
+
+// requestContext attaches the trace, client ip, caller identity and per request collectors to the request context
+func requestContext(ctx context.Context, header http.Header, clientIP string, cfg *middlewareConfig) (context.Context, string) {
+	traceID := stringFromContext(ctx, TraceIDKey)
+	if traceID == "" {
+		if cfg.headers.Trace != "" {
+			traceID = header.Get(cfg.headers.Trace)
+		}
+		if traceID == "" {
+			// No upstream trace, generate one prefixed with the service name
+			traceID = NewTraceID()
+		}
+		ctx = context.WithValue(ctx, TraceIDKey, traceID)
+	}
+
+	// Attach context values for IP
+	ctx = context.WithValue(ctx, IPKey, NormalizeIP(clientIP))
+	// Operator and merchant set by upstream code take precedence over the headers
+	for key, name := range map[ctxKey]string{OperatorKey: cfg.headers.Operator, MerchantKey: cfg.headers.Merchant} {
+		if name == "" || stringFromContext(ctx, key) != "" {
+			continue
+		}
+		if v := header.Get(name); v != "" {
+			ctx = context.WithValue(ctx, key, v)
 		}
 	}
+	// Collect feature flags evaluated during the request
+	ctx = withFlagSet(ctx)
+	ctx = withCacheStatus(ctx)
+	if cfg.verboseReplay {
+		ctx = withReplayBuffer(ctx)
+	}
+	return ctx, traceID
+}
+
+// recordRequestOperation writes the operation log of a completed request
+func recordRequestOperation(ctx context.Context, cfg *middlewareConfig, r *http.Request, route string, status int, reqBytes, respBytes int64) {
+	if !auditable(r.Method) {
+		return
+	}
+	rec := Operation(ctx).
+		Method(r.Method).
+		Path(r.URL.Path).
+		Route(route).
+		RespCode(status)
+	if cfg.headers.User != "" {
+		rec.User(r.Header.Get(cfg.headers.User))
+	}
+	if cfg.headers.Device != "" {
+		rec.Device(r.Header.Get(cfg.headers.Device))
+	}
+	rec.op.ReqBytes = reqBytes
+	rec.op.RespBytes = respBytes
+	if err := rec.Record(); err != nil {
+		Log(ctx).WithError(err).
+			WithField("method", r.Method).
+			WithField("path", r.URL.Path).
+			Warn("failed to record operation log")
+	}
+}
+
+// safeHeaders flattens request headers and drops sensitive ones
+func safeHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for k, v := range header {
+		headers[k] = strings.Join(v, ",")
+	}
+	for _, k := range sensitiveHeaders {
+		delete(headers, k)
+	}
+	return headers
 }
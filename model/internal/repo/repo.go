@@ -0,0 +1,96 @@
+// Package repo 操作日志与登录日志仓库共用的分页、时间范围及清理
+package repo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// defaultListLimit 默认每页记录数
+const defaultListLimit = 20
+
+// ListOptions 分页参数，按写入时间倒序返回
+// 设置 After 时使用游标分页(上一页返回的游标)，否则使用 Skip 分页
+type ListOptions struct {
+	// 每页记录数，默认 20
+	Limit int64
+	// 跳过的记录数
+	Skip int64
+	// 游标
+	After string
+}
+
+// List 按 _id 倒序查询，返回记录及下一页游标，没有更多记录时游标为空
+// id 返回记录的 _id，用作下一页的游标
+func List[T any](ctx context.Context, coll *mongo.Collection, filter bson.M, opts ListOptions, id func(T) primitive.ObjectID) ([]T, string, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(limit)
+	if opts.After != "" {
+		after, err := primitive.ObjectIDFromHex(opts.After)
+		if err != nil {
+			return nil, "", err
+		}
+		// 与时间范围同样作用于 _id，使用 $and 合并
+		filter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$lt": after}}}}
+	} else if opts.Skip > 0 {
+		findOpts.SetSkip(opts.Skip)
+	}
+
+	cursor, err := coll.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, "", err
+	}
+	var records []T
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if int64(len(records)) == limit {
+		next = id(records[len(records)-1]).Hex()
+	}
+	return records, next, nil
+}
+
+// TimeRange 按 _id 中的写入时间过滤 [from, to)，精确到秒，零值表示不限
+// 返回 nil 表示不过滤
+func TimeRange(from, to time.Time) bson.M {
+	tr := bson.M{}
+	if !from.IsZero() {
+		tr["$gte"] = primitive.NewObjectIDFromTimestamp(from)
+	}
+	if !to.IsZero() {
+		tr["$lt"] = primitive.NewObjectIDFromTimestamp(to)
+	}
+	if len(tr) == 0 {
+		return nil
+	}
+	return tr
+}
+
+// Indexes 返回按字段过滤并按 _id 倒序排序的复合索引
+func Indexes(fields ...string) []mongo.IndexModel {
+	indexes := make([]mongo.IndexModel, len(fields))
+	for i, field := range fields {
+		indexes[i] = mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}, {Key: "_id", Value: -1}}}
+	}
+	return indexes
+}
+
+// Purge 删除 _id 中的写入时间早于 olderThan 的记录，返回删除的数量
+func Purge(ctx context.Context, coll *mongo.Collection, olderThan time.Duration) (int64, error) {
+	cutoff := primitive.NewObjectIDFromTimestamp(time.Now().Add(-olderThan))
+	result, err := coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,37 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTimeRange(t *testing.T) {
+	if tr := TimeRange(time.Time{}, time.Time{}); tr != nil {
+		t.Errorf("zero range = %v", tr)
+	}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	tr := TimeRange(from, to)
+	gte, _ := tr["$gte"].(primitive.ObjectID)
+	lt, _ := tr["$lt"].(primitive.ObjectID)
+	if !gte.Timestamp().Equal(from) || !lt.Timestamp().Equal(to) {
+		t.Errorf("range = %v", tr)
+	}
+	// 该时间段内生成的 id 落在范围内
+	id := primitive.NewObjectIDFromTimestamp(from.Add(time.Minute))
+	if id.Hex() < gte.Hex() || id.Hex() >= lt.Hex() {
+		t.Errorf("id %s outside [%s, %s)", id.Hex(), gte.Hex(), lt.Hex())
+	}
+}
+
+func TestIndexesSortOnID(t *testing.T) {
+	for _, index := range Indexes("user_id", "operator") {
+		keys := index.Keys.(bson.D)
+		if len(keys) != 2 || keys[1].Key != "_id" || keys[1].Value != -1 {
+			t.Errorf("index keys = %v", keys)
+		}
+	}
+}
@@ -31,7 +31,13 @@ type Model struct {
 	// 创建时（用户上传的数据为空，所以默认可以不传该值)
 	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 
+	// 登录时间（毫秒）
+	Timestamp uint64 `json:"timestamp" bson:"timestamp"`
 	// 用户根据业务需求定义的字段
+	// 组织id（商户号记录在 Meta.MerchantID）
+	OrgID string `json:"org_id"  bson:"org_id"`
+	// 门店id
+	StoreID string `json:"store_id"  bson:"store_id"`
 	// 客户IP
 	ClientIP string `json:"client_ip" bson:"client_ip"`
 	// 远程IP
@@ -46,6 +52,18 @@ type Model struct {
 	TargetID string `json:"target_id"  bson:"target_id"`
 	// 设备号
 	Device string `json:"device"  bson:"device"`
+	// 设备类型 mobile/tablet/desktop/bot，由 user agent 解析
+	DeviceType string `json:"device_type"  bson:"device_type"`
+	// 客户端 user agent
+	UserAgent string `json:"user_agent"  bson:"user_agent"`
+	// 所在国家
+	GeoCountry string `json:"geo_country"  bson:"geo_country"`
+	// 所在城市
+	GeoCity string `json:"geo_city"  bson:"geo_city"`
+	// 工作流 id
+	WorkflowID string `json:"workflow_id,omitempty"  bson:"workflow_id,omitempty"`
+	// 工作流步骤
+	StepName string `json:"step_name,omitempty"  bson:"step_name,omitempty"`
 	// 日志类型
 	LogType string `json:"log_type"  bson:"log_type"`
 	// 用户id
@@ -0,0 +1,295 @@
+package operation
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maskedValue 标记为 audit:"mask" 的字段记录的值
+const maskedValue = "******"
+
+// Change 字段级别的变更
+type Change struct {
+	// 字段路径，例如 address.city、items[0].price
+	Path string `json:"path" bson:"path"`
+	// 修改前的值，新增字段时为空
+	Old interface{} `json:"old,omitempty" bson:"old,omitempty"`
+	// 修改后的值，删除字段时为空
+	New interface{} `json:"new,omitempty" bson:"new,omitempty"`
+}
+
+// DiffValues 比较修改前后的结构体/map/bson 文档，返回按路径排序的字段变更
+// 结构体字段名优先使用 bson 标签，其次 json 标签，支持 bson:",inline"
+// 使用 audit 标签控制字段:
+//
+//	audit:"-"     不参与比较，例如更新时间等噪音字段
+//	audit:"mask"  记录变更但不记录值，例如密码
+//
+// 长度不同的数组整体作为一个变更，包含循环引用时返回错误
+func DiffValues(before, after interface{}) ([]Change, error) {
+	a, err := newNormalizer().normalize(reflect.ValueOf(before))
+	if err != nil {
+		return nil, err
+	}
+	b, err := newNormalizer().normalize(reflect.ValueOf(after))
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	diffTree("", a, b, &changes)
+	return changes, nil
+}
+
+// masked 标记需要隐藏值的字段
+type masked struct {
+	value interface{}
+}
+
+// visitKey 正在展开的指针、map 或切片
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// normalizer 记录当前路径上正在展开的引用，遇到循环引用时返回错误
+type normalizer struct {
+	visiting map[visitKey]bool
+}
+
+func newNormalizer() *normalizer {
+	return &normalizer{visiting: make(map[visitKey]bool)}
+}
+
+// enter 标记引用开始展开，引用已在当前路径上时返回错误，返回的函数在展开结束后调用
+func (n *normalizer) enter(v reflect.Value) (func(), error) {
+	if v.Pointer() == 0 || (v.Kind() == reflect.Slice && v.Len() == 0) {
+		return func() {}, nil
+	}
+	key := visitKey{ptr: v.Pointer(), typ: v.Type()}
+	if n.visiting[key] {
+		return nil, fmt.Errorf("cyclic value of type %s", v.Type())
+	}
+	n.visiting[key] = true
+	return func() { delete(n.visiting, key) }, nil
+}
+
+// normalize 将值转换为 map[string]interface{}/[]interface{}/基本类型组成的树
+func (n *normalizer) normalize(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch val := v.Interface().(type) {
+	case time.Time, primitive.ObjectID, primitive.DateTime, primitive.Decimal128:
+		return val, nil
+	case bson.D:
+		m := make(map[string]interface{}, len(val))
+		for _, e := range val {
+			child, err := n.normalize(reflect.ValueOf(e.Value))
+			if err != nil {
+				return nil, err
+			}
+			m[e.Key] = child
+		}
+		return m, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			break
+		}
+		leave, err := n.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return n.normalize(v.Elem())
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		if err := n.normalizeStruct(v, m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			child, err := n.normalize(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			m[iter.Key().String()] = child
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return fmt.Sprintf("%x", v.Interface()), nil
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			child, err := n.normalize(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			s[i] = child
+		}
+		return s, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	}
+	return v.Interface(), nil
+}
+
+// normalizeStruct 按标签展开结构体字段
+func (n *normalizer) normalizeStruct(v reflect.Value, m map[string]interface{}) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			// 未导出字段
+			continue
+		}
+		audit := sf.Tag.Get("audit")
+		if audit == "-" {
+			continue
+		}
+		name, inline := fieldName(sf)
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if inline {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := n.normalizeStruct(fv, m); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		child, err := n.normalize(fv)
+		if err != nil {
+			return err
+		}
+		if audit == "mask" {
+			child = masked{value: child}
+		}
+		m[name] = child
+	}
+	return nil
+}
+
+// fieldName 返回字段在文档中的名称及是否内联
+func fieldName(sf reflect.StructField) (string, bool) {
+	for _, key := range []string{"bson", "json"} {
+		tag, ok := sf.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		inline := key == "bson" && strings.Contains(opts, "inline")
+		if name != "" || inline {
+			return name, inline
+		}
+	}
+	return strings.ToLower(sf.Name), false
+}
+
+// diffTree 递归比较，记录变更
+func diffTree(path string, a, b interface{}, changes *[]Change) {
+	if ma, ok := a.(masked); ok {
+		mb, _ := b.(masked)
+		if !reflect.DeepEqual(ma.value, mb.value) {
+			*changes = append(*changes, Change{Path: path, Old: maskedValue, New: maskedValue})
+		}
+		return
+	}
+
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if aok && bok {
+		keys := make([]string, 0, len(am)+len(bm))
+		for k := range am {
+			keys = append(keys, k)
+		}
+		for k := range bm {
+			if _, ok := am[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diffTree(joinPath(path, k), am[k], bm[k], changes)
+		}
+		return
+	}
+
+	as, aok := a.([]interface{})
+	bs, bok := b.([]interface{})
+	if aok && bok && len(as) == len(bs) {
+		for i := range as {
+			diffTree(path+"["+strconv.Itoa(i)+"]", as[i], bs[i], changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Path: path, Old: hideMasked(a), New: hideMasked(b)})
+	}
+}
+
+// hideMasked 替换子树中需要隐藏的值
+func hideMasked(v interface{}) interface{} {
+	switch val := v.(type) {
+	case masked:
+		return maskedValue
+	case map[string]interface{}:
+		for k, child := range val {
+			val[k] = hideMasked(child)
+		}
+	case []interface{}:
+		for i, child := range val {
+			val[i] = hideMasked(child)
+		}
+	}
+	return v
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,42 @@
+package operation
+
+import (
+	"strings"
+	"testing"
+)
+
+type node struct {
+	Name string `json:"name"`
+	Next *node  `json:"next"`
+}
+
+func TestDiffValuesCycle(t *testing.T) {
+	a := &node{Name: "a"}
+	a.Next = a
+	_, err := DiffValues(a, &node{Name: "b"})
+	if err == nil || !strings.Contains(err.Error(), "cyclic") {
+		t.Fatalf("err = %v", err)
+	}
+
+	m := map[string]interface{}{}
+	m["self"] = m
+	if _, err := DiffValues(m, map[string]interface{}{}); err == nil {
+		t.Fatal("map cycle not detected")
+	}
+}
+
+func TestDiffValuesSharedPointer(t *testing.T) {
+	// 同一指针出现在不同字段中不是循环引用
+	shared := &node{Name: "shared"}
+	type pair struct {
+		A *node `json:"a"`
+		B *node `json:"b"`
+	}
+	changes, err := DiffValues(pair{A: shared, B: shared}, pair{A: shared, B: &node{Name: "new"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Path != "b.name" {
+		t.Errorf("changes = %+v", changes)
+	}
+}
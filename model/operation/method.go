@@ -1,5 +1,10 @@
 package operation
 
+import (
+	"reflect"
+	"strings"
+)
+
 // ResourceName 返回资源名称
 func (m *Model) ResourceName() string {
 	return modelName
@@ -9,3 +14,58 @@ func (m *Model) ResourceName() string {
 func (m *Model) CollectionName() string {
 	return collectionNamePrefix + modelName + collectionNameSuffix
 }
+
+// AddStep 记录一个步骤的执行结果
+func (m *Model) AddStep(name string, ok bool) *Model {
+	m.Steps = append(m.Steps, StepResult{Name: name, OK: ok})
+	return m
+}
+
+// SetChanges 比较修改前后的数据，记录到 Changes
+// Before/After 的原始快照需要调用方另外设置
+func (m *Model) SetChanges(before, after interface{}) error {
+	changes, err := DiffValues(before, after)
+	if err != nil {
+		return err
+	}
+	m.Changes = changes
+	return nil
+}
+
+// diffExcluded 默认不参与比较的字段
+var diffExcluded = map[string]bool{
+	"Model":         true,
+	"ID":            true,
+	"Timestamp":     true,
+	"SchemaVersion": true,
+}
+
+// Diff 比较两条操作日志，返回不同的字段
+// 键为字段的 json 名称，值为 {"before": 当前值, "after": other 的值}
+// id、时间戳等字段不参与比较
+func (m Model) Diff(other Model) map[string]interface{} {
+	diff := make(map[string]interface{})
+	a := reflect.ValueOf(m)
+	b := reflect.ValueOf(other)
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if diffExcluded[f.Name] {
+			continue
+		}
+		before := a.Field(i).Interface()
+		after := b.Field(i).Interface()
+		if reflect.DeepEqual(before, after) {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		diff[name] = map[string]interface{}{
+			"before": before,
+			"after":  after,
+		}
+	}
+	return diff
+}
@@ -15,6 +15,17 @@ const (
 	collectionNameSuffix = "_log"
 	// 这个需要用户根据具体业务完成设定
 	modelName = "operation"
+	// CurrentSchemaVersion 当前记录结构版本，每次修改 Model 字段时需要递增
+	// 1: 初始结构（历史记录中没有 schema_version 字段）
+	// 2: 增加 ip_version, steps, req_bytes, resp_bytes
+	// 3: 增加 idempotency_key, duplicate_request
+	// 4: 增加 authenticated
+	// 5: 增加 route
+	// 6: 增加 trace
+	// 7: 增加 org_id, store_id
+	// 8: 增加 workflow_id, step_name
+	// 9: 增加 changes
+	CurrentSchemaVersion = 9
 )
 
 // 每一个应用表示一个大的模块，通常其子模块是一个个接口
@@ -32,13 +43,23 @@ type Model struct {
 	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 
 	Timestamp uint64 `json:"timestamp" bson:"timestamp"`
+	// 记录结构版本，由写入方自动设置
+	SchemaVersion int `json:"schema_version" bson:"schema_version"`
 	// 用户根据业务需求定义的字段
+	// 组织id（商户号记录在 Meta.MerchantID）
+	OrgID string `json:"org_id"  bson:"org_id"`
+	// 门店id
+	StoreID string `json:"store_id"  bson:"store_id"`
 	// 客户IP
 	ClientIP string `json:"client_ip" bson:"client_ip"`
 	// 远程IP
 	RemoteIP string `json:"remote_ip"  bson:"remote_ip"`
+	// 客户IP版本 4/6
+	IPVersion int `json:"ip_version"  bson:"ip_version"`
 	// 路径
 	FullPath string `json:"full_path"  bson:"full_path"`
+	// 匹配的路由模板，例如 /orders/:id
+	Route string `json:"route"  bson:"route"`
 	// 请求方法/操作
 	Method string `json:"method"  bson:"method"`
 	// 相应代码
@@ -49,6 +70,14 @@ type Model struct {
 	Device string `json:"device"  bson:"device"`
 	// 操作人
 	Operator string `json:"operator"  bson:"operator"`
+	// 请求 trace id
+	Trace string `json:"trace"  bson:"trace"`
+	// 工作流 id
+	WorkflowID string `json:"workflow_id,omitempty"  bson:"workflow_id,omitempty"`
+	// 工作流步骤
+	StepName string `json:"step_name,omitempty"  bson:"step_name,omitempty"`
+	// 是否已认证
+	Authenticated bool `json:"authenticated"  bson:"authenticated"`
 	// 用户id
 	UserID string `json:"user_id"  bson:"user_id"`
 	// 账号id
@@ -57,4 +86,24 @@ type Model struct {
 	Before string `json:"before"  bson:"before"`
 	// 修改后
 	After string `json:"after"  bson:"after"`
+	// 修改前后字段级别的变更
+	Changes []Change `json:"changes,omitempty"  bson:"changes,omitempty"`
+	// 幂等键
+	IdempotencyKey string `json:"idempotency_key,omitempty"  bson:"idempotency_key,omitempty"`
+	// 相同幂等键是否已出现过（重复提交）
+	DuplicateRequest bool `json:"duplicate_request,omitempty"  bson:"duplicate_request,omitempty"`
+	// 多步骤操作中每一步的执行结果（按执行顺序）
+	Steps []StepResult `json:"steps,omitempty"  bson:"steps,omitempty"`
+	// 请求体大小（字节）
+	ReqBytes int64 `json:"req_bytes"  bson:"req_bytes"`
+	// 响应体大小（字节）
+	RespBytes int64 `json:"resp_bytes"  bson:"resp_bytes"`
+}
+
+// StepResult 操作步骤执行结果
+type StepResult struct {
+	// 步骤名称
+	Name string `json:"name" bson:"name"`
+	// 是否成功
+	OK bool `json:"ok" bson:"ok"`
 }
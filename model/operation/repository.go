@@ -0,0 +1,96 @@
+package operation
+
+import (
+	"context"
+	"github.com/open4go/log/model/internal/repo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// merchantIDKey 商户号在文档中的路径，model.Model 未内联，字段位于 model 子文档下
+const merchantIDKey = "model.meta.merchant_id"
+
+// Filter 查询条件，零值字段不参与过滤
+type Filter struct {
+	// 商户号
+	MerchantID string
+	// 操作人
+	Operator string
+	// 用户id
+	UserID string
+	// 请求方法
+	Method string
+	// 响应代码
+	RespCode int
+	// 操作对象id
+	TargetID string
+	// 写入时间范围 [From, To)，按 _id 中的时间过滤，精确到秒
+	From time.Time
+	To   time.Time
+}
+
+// ListOptions 分页参数，按写入时间倒序返回
+// 设置 After 时使用游标分页(上一页返回的游标)，否则使用 Skip 分页
+type ListOptions = repo.ListOptions
+
+// Repository 操作日志的查询及清理
+type Repository struct {
+	coll *mongo.Collection
+}
+
+// NewRepository 创建操作日志仓库
+func NewRepository(db *mongo.Database) *Repository {
+	return &Repository{coll: db.Collection((&Model{}).CollectionName())}
+}
+
+// List 查询操作日志，返回记录及下一页游标，没有更多记录时游标为空
+func (r *Repository) List(ctx context.Context, f Filter, opts ListOptions) ([]Model, string, error) {
+	return repo.List(ctx, r.coll, f.bson(), opts, func(m Model) primitive.ObjectID { return m.ID })
+}
+
+// Count 统计符合条件的操作日志数量
+func (r *Repository) Count(ctx context.Context, f Filter) (int64, error) {
+	return r.coll.CountDocuments(ctx, f.bson())
+}
+
+// EnsureIndexes 创建常用查询字段的索引，可以在服务启动时重复调用
+func (r *Repository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.coll.Indexes().CreateMany(ctx, repo.Indexes(
+		merchantIDKey,
+		"operator",
+		"user_id",
+		"target_id",
+	))
+	return err
+}
+
+// Purge 删除写入时间早于 olderThan 的操作日志，返回删除的数量
+// 按 _id 中的写入时间删除，需要定期调用
+func (r *Repository) Purge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	return repo.Purge(ctx, r.coll, olderThan)
+}
+
+// bson 转换为查询条件
+func (f Filter) bson() bson.M {
+	filter := bson.M{}
+	for key, value := range map[string]string{
+		merchantIDKey: f.MerchantID,
+		"operator":    f.Operator,
+		"user_id":     f.UserID,
+		"method":      f.Method,
+		"target_id":   f.TargetID,
+	} {
+		if value != "" {
+			filter[key] = value
+		}
+	}
+	if f.RespCode != 0 {
+		filter["resp_code"] = f.RespCode
+	}
+	if tr := repo.TimeRange(f.From, f.To); tr != nil {
+		filter["_id"] = tr
+	}
+	return filter
+}
@@ -0,0 +1,24 @@
+package operation
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMerchantIDKeyPath(t *testing.T) {
+	m := Model{}
+	m.Meta.MerchantID = "m-1"
+	b, err := bson.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := bson.Raw(b).LookupErr(strings.Split(merchantIDKey, ".")...)
+	if err != nil {
+		t.Fatalf("%s not found in %s: %v", merchantIDKey, bson.Raw(b), err)
+	}
+	if v.StringValue() != "m-1" {
+		t.Errorf("%s = %s", merchantIDKey, v)
+	}
+}
@@ -0,0 +1,215 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"sync"
+	"sync/atomic"
+)
+
+// noLevelOverride 未单独设置级别，跟随全局级别
+const noLevelOverride = -1
+
+// Logger 模块日志，通过 Named 创建
+// 与全局日志共用输出、格式及 hook，可以单独设置级别并附加固定字段
+type Logger struct {
+	name   string
+	core   *namedCore
+	fields logrus.Fields
+	base   atomic.Pointer[namedBase]
+}
+
+// namedCore 同一模块的所有 Logger 共用的 logrus 实例及级别
+type namedCore struct {
+	logger   *logrus.Logger
+	override atomic.Int32
+}
+
+// namedBase 缓存的模块基础日志条目，全局静态字段变化后重建
+type namedBase struct {
+	static *logrus.Entry
+	entry  *logrus.Entry
+}
+
+var (
+	namedMu sync.Mutex
+	// namedLoggers 按模块名缓存，同名模块返回同一个 Logger
+	namedLoggers = map[string]*Logger{}
+	// namedCores 以 logrus 实例查找模块，用于判断模块是否单独设置了级别
+	namedCores sync.Map
+)
+
+// Named 返回模块日志，日志会附加 module 字段
+// 级别默认跟随全局级别，可以通过 log.levels.<name> 配置或 SetLevel 单独设置，例如只对支付模块开启 debug:
+//
+//	log:
+//	  levels:
+//	    payment: debug
+//
+//	var payLog = log.Named("payment")
+//	payLog.Log(ctx).Debug("callback received")
+//	payLog.ErrorWithStack(ctx, err)
+func Named(name string) *Logger {
+	namedMu.Lock()
+	defer namedMu.Unlock()
+	if l, ok := namedLoggers[name]; ok {
+		return l
+	}
+
+	child := newChildLogger(loggerLevel(logrus.Level(outputLevel.Load())))
+	core := &namedCore{logger: child}
+	core.override.Store(noLevelOverride)
+	namedCores.Store(child, core)
+
+	l := &Logger{name: name, core: core}
+	if level := viper.GetString("log.levels." + name); level != "" {
+		if err := l.SetLevel(level); err != nil {
+			logger.WithError(err).WithField("module", name).Warn("invalid module log level")
+		}
+	}
+	namedLoggers[name] = l
+	return l
+}
+
+// WithFields 返回附加固定字段的 Logger，与原 Logger 共用级别
+func (l *Logger) WithFields(fields logrus.Fields) *Logger {
+	merged := make(logrus.Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{name: l.name, core: l.core, fields: merged}
+}
+
+// SetLevel 单独设置模块的日志级别，传入空字符串恢复为跟随全局级别
+func (l *Logger) SetLevel(logLevel string) error {
+	if logLevel == "" {
+		l.core.override.Store(noLevelOverride)
+		return nil
+	}
+	level, ok := levels[logLevel]
+	if !ok {
+		return fmt.Errorf("unknown log level: %s", logLevel)
+	}
+	l.core.override.Store(int32(level))
+	return nil
+}
+
+// GetLevel 返回模块当前的日志级别
+func (l *Logger) GetLevel() string {
+	if override := l.core.override.Load(); override != noLevelOverride {
+		return levelName(logrus.Level(override))
+	}
+	return GetLevel()
+}
+
+// Log 返回携带上下文信息的日志条目
+func (l *Logger) Log(ctx context.Context) *logrus.Entry {
+	return l.entry(ctx, 1)
+}
+
+// ErrorWithStack 输出带有堆栈信息的错误日志
+// 未传入 args 时使用 err.Error() 作为日志信息
+func (l *Logger) ErrorWithStack(ctx context.Context, err error, args ...interface{}) {
+	entry := withErrorDetails(l.entry(ctx, 1), err)
+	if len(args) == 0 && err != nil {
+		args = []interface{}{err.Error()}
+	}
+	entry.Error(args...)
+}
+
+// ErrorfWithStack 输出带有堆栈信息的格式化错误日志，同时记录 msg_template
+func (l *Logger) ErrorfWithStack(ctx context.Context, err error, format string, args ...interface{}) {
+	withErrorDetails(l.entry(ctx, 1), err).
+		WithField(msgTemplateKey, format).
+		Errorf(format, args...)
+}
+
+// entry 同步级别后构建日志条目
+func (l *Logger) entry(ctx context.Context, skip int) *logrus.Entry {
+	level := logrus.Level(outputLevel.Load())
+	if override := l.core.override.Load(); override != noLevelOverride {
+		level = logrus.Level(override)
+	}
+	level = loggerLevel(level)
+	if l.core.logger.GetLevel() != level {
+		l.core.logger.SetLevel(level)
+	}
+	return buildEntry(l.baseEntry(), ctx, skip+1)
+}
+
+// baseEntry 返回包含全局静态字段、模块固定字段及 module 字段的基础日志条目
+func (l *Logger) baseEntry() *logrus.Entry {
+	static := staticBaseEntry()
+	if base := l.base.Load(); base != nil && base.static == static {
+		return base.entry
+	}
+	entry := logrus.NewEntry(l.core.logger).
+		WithFields(static.Data).
+		WithFields(l.fields).
+		WithField("module", l.name)
+	l.base.Store(&namedBase{static: static, entry: entry})
+	return entry
+}
+
+// hasLevelOverride 返回日志条目是否来自单独设置了级别的模块
+func hasLevelOverride(entry *logrus.Entry) bool {
+	v, ok := namedCores.Load(entry.Logger)
+	return ok && v.(*namedCore).override.Load() != noLevelOverride
+}
+
+// entryOutputLevel 返回日志条目在主输出的级别，单独设置了级别的模块使用模块级别
+func entryOutputLevel(entry *logrus.Entry) logrus.Level {
+	if entry.Logger != logger {
+		if v, ok := namedCores.Load(entry.Logger); ok {
+			if override := v.(*namedCore).override.Load(); override != noLevelOverride {
+				return logrus.Level(override)
+			}
+		}
+	}
+	return logrus.Level(outputLevel.Load())
+}
+
+// refreshNamedLevels 重新读取 log.levels 配置
+func refreshNamedLevels() {
+	namedMu.Lock()
+	defer namedMu.Unlock()
+	for name, l := range namedLoggers {
+		if err := l.SetLevel(viper.GetString("log.levels." + name)); err != nil {
+			logger.WithError(err).WithField("module", name).Warn("invalid module log level")
+		}
+	}
+}
+
+// newChildLogger 创建与全局日志共用输出、格式及 hook 的 logrus 实例
+// hook 统一由 hookChain 调度，子实例只注册 hookChain，之后通过 AddHook 注册的 hook 同样生效
+func newChildLogger(level logrus.Level) *logrus.Logger {
+	child := logrus.New()
+	child.Out = parentOutput{}
+	child.Formatter = parentFormatter{}
+	child.Hooks = make(logrus.LevelHooks)
+	child.Hooks.Add(hooks)
+	child.ExitFunc = func(code int) {
+		logger.ExitFunc(code)
+	}
+	child.SetLevel(level)
+	return child
+}
+
+// parentOutput 写入全局日志当前的输出，与全局日志共用同一把锁
+type parentOutput struct{}
+
+func (parentOutput) Write(p []byte) (int, error) {
+	return output.Load().Write(p)
+}
+
+// parentFormatter 使用全局日志当前的格式
+type parentFormatter struct{}
+
+func (parentFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return (*formatter.Load()).Format(entry)
+}
@@ -0,0 +1,53 @@
+package log
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// nopHook 不做任何处理的 hook
+type nopHook struct{}
+
+func (nopHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (nopHook) Fire(*logrus.Entry) error { return nil }
+
+func TestNamedConcurrentWithParent(t *testing.T) {
+	buf := captureOutput(t)
+	l := Named("named-test")
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				l.Log(ctx).Info("from module")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				Log(ctx).Info("from parent")
+			}
+		}()
+	}
+	// 并发注册 hook 不影响模块日志
+	AddHookWithPriority(nopHook{}, PriorityDefault)
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 400 {
+		t.Fatalf("got %d lines, want 400", len(lines))
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "{") || !strings.HasSuffix(line, "}") {
+			t.Fatalf("interleaved line: %q", line)
+		}
+	}
+}
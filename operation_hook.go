@@ -0,0 +1,187 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"github.com/open4go/log/model/operation"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+)
+
+// auditMarkerKey 标记需要写入操作日志的日志条目
+const auditMarkerKey = "audit"
+
+// operationHookQueueSize 异步写入队列长度，队列满时丢弃并输出到 stderr
+const operationHookQueueSize = 1024
+
+// operationHook 将带有 audit=true 标记的日志写入操作日志表
+type operationHook struct {
+	queue chan queuedOperation
+
+	// mu 保证停止后不再有记录进入队列
+	mu      sync.RWMutex
+	closed  bool
+	stopped chan struct{}
+}
+
+// NewOperationHook 创建写入操作日志的 hook，在 Init 之后注册，建议使用 PriorityPersist 保证在脱敏之后执行
+//
+//	hook, stop := log.NewOperationHook()
+//	defer stop()
+//	log.AddHookWithPriority(hook, log.PriorityPersist)
+//	log.Log(ctx).WithField("audit", true).
+//		WithField("method", "PUT").
+//		WithField("full_path", "/orders/1").
+//		WithField("resp_code", 200).
+//		Info("order updated")
+//
+// 日志中的 merchantId/operator/trace 等字段会映射到 operation.Model，时间戳使用日志时间
+// 与 AuditLog 相同写入 SetAuditDB 设置的数据库及 RegisterAuditSink 注册的写入目标，并输出写入成功或失败的日志
+// 写入在后台协程中进行，不会阻塞请求处理
+// 返回的函数停止写入并等待队列中的记录全部写入，应在服务退出前调用；Fatal 退出时同样会自动写入
+func NewOperationHook() (logrus.Hook, func()) {
+	h := &operationHook{
+		queue:   make(chan queuedOperation, operationHookQueueSize),
+		stopped: make(chan struct{}),
+	}
+	go h.run()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			h.closed = true
+			close(h.queue)
+			h.mu.Unlock()
+			<-h.stopped
+		})
+	}
+	RegisterExitHandler(stop)
+	return h, stop
+}
+
+// Levels info 及以上级别
+func (h *operationHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel, logrus.WarnLevel, logrus.InfoLevel}
+}
+
+// Fire 将带标记的日志转换为操作日志并放入写入队列
+func (h *operationHook) Fire(entry *logrus.Entry) error {
+	if marker, _ := entry.Data[auditMarkerKey].(bool); !marker {
+		return nil
+	}
+	op := operationFromEntry(entry)
+	if !auditable(op.Method) {
+		return nil
+	}
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	prepareOperation(ctx, op)
+
+	h.mu.RLock()
+	if h.closed {
+		h.mu.RUnlock()
+		// hook 已停止，回退到同步写入
+		h.write(queuedOperation{ctx: ctx, op: op})
+		return nil
+	}
+	defer h.mu.RUnlock()
+	select {
+	case h.queue <- queuedOperation{ctx: ctx, op: op}:
+	default:
+		fmt.Fprintf(os.Stderr, "operation log queue is full, drop audit record %s\n", op.ID.Hex())
+	}
+	return nil
+}
+
+// run 后台写入操作日志，停止时写入队列中剩余的记录
+func (h *operationHook) run() {
+	defer close(h.stopped)
+	for item := range h.queue {
+		h.write(item)
+	}
+}
+
+// write 写入全部审计写入目标，失败的写入目标由 writeAuditSinks 输出日志
+func (h *operationHook) write(item queuedOperation) {
+	sinks := getAuditSinks()
+	if len(sinks) == 0 {
+		fmt.Fprintf(os.Stderr, "failed to write operation log %s: %v\n", item.op.ID.Hex(), ErrNoAuditSink)
+		return
+	}
+	// 保留上下文中的值，但不受请求取消的影响
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(item.ctx), operationWriteTimeout)
+	defer cancel()
+	if err := writeAuditSinks(item.ctx, ctx, sinks, item.op, 1); err != nil {
+		return
+	}
+	auditWritten(item.ctx, item.op, 1)
+}
+
+// operationFromEntry 将日志字段映射为操作日志
+func operationFromEntry(entry *logrus.Entry) *operation.Model {
+	data := entry.Data
+	op := &operation.Model{
+		Timestamp: uint64(entry.Time.UnixMilli()),
+		ClientIP:  firstString(data, "client_ip", "ip"),
+		RemoteIP:  firstString(data, "remote_ip"),
+		FullPath:  firstString(data, "full_path", "path"),
+		Route:     firstString(data, "route"),
+		Method:    firstString(data, "method"),
+		RespCode:  int(firstInt(data, "resp_code", "status")),
+		TargetID:  firstString(data, "target_id"),
+		Device:    firstString(data, "device"),
+		Operator:  firstString(data, "operator"),
+		UserID:    firstString(data, "user_id"),
+		AccountID: firstString(data, "account_id"),
+		Trace:     firstString(data, "trace"),
+		Before:    firstString(data, "before"),
+		After:     firstString(data, "after"),
+		ReqBytes:  firstInt(data, "req_bytes"),
+		RespBytes: firstInt(data, "resp_bytes"),
+	}
+	op.Meta.MerchantID = firstString(data, "merchantId")
+	op.Meta.AccountID = op.AccountID
+	return op
+}
+
+// firstString 返回第一个存在的字段的字符串值
+func firstString(data logrus.Fields, keys ...string) string {
+	for _, k := range keys {
+		v, ok := data[k]
+		if !ok || v == nil {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// firstInt 返回第一个存在的数值字段
+func firstInt(data logrus.Fields, keys ...string) int64 {
+	for _, k := range keys {
+		switch v := data[k].(type) {
+		case int:
+			return int64(v)
+		case int32:
+			return int64(v)
+		case int64:
+			return v
+		case uint:
+			return int64(v)
+		case uint32:
+			return int64(v)
+		case uint64:
+			return int64(v)
+		case float64:
+			return int64(v)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+// Package otellog 将日志与 OpenTelemetry 链路关联
+//
+//	import "github.com/open4go/log/otellog"
+//
+//	otellog.Enable()
+//
+// 开启后 log.Log(ctx) 等输出的日志会附加当前 span 的 trace_id/span_id (W3C 格式)，
+// log.ErrorWithStack 等输出的错误会记录到当前 span 并将 span 状态标记为 Error
+package otellog
+
+import (
+	"context"
+	"errors"
+	"github.com/open4go/log"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+)
+
+var enableOnce sync.Once
+
+// Enable 开启 OpenTelemetry 关联，需在 log.Init 之后调用，重复调用无效果
+func Enable() {
+	enableOnce.Do(func() {
+		log.RegisterContextExtractor(spanFields)
+		log.AddHookWithPriority(&spanErrorHook{}, log.PriorityPersist)
+	})
+}
+
+// spanFields 返回当前 span 的 trace_id/span_id
+func spanFields(ctx context.Context) logrus.Fields {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return logrus.Fields{
+		"trace_id":    sc.TraceID().String(),
+		"span_id":     sc.SpanID().String(),
+		"trace_flags": sc.TraceFlags().String(),
+	}
+}
+
+// spanErrorHook 将带堆栈的错误日志记录到当前 span
+type spanErrorHook struct{}
+
+// Levels 仅处理 error 及以上级别
+func (h *spanErrorHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+// Fire 只处理 ErrorWithStack 等附加了 stacktrace 的日志
+func (h *spanErrorHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if _, ok := entry.Data["stacktrace"]; !ok {
+		return nil
+	}
+	span := trace.SpanFromContext(entry.Context)
+	if !span.IsRecording() {
+		return nil
+	}
+	err, _ := entry.Data[logrus.ErrorKey].(error)
+	if err == nil {
+		err = errors.New(entry.Message)
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, entry.Message)
+	return nil
+}
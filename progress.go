@@ -0,0 +1,66 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval 进度日志的最小输出间隔，单位纳秒
+var progressInterval atomic.Int64
+
+func init() {
+	progressInterval.Store(int64(10 * time.Second))
+}
+
+// SetProgressInterval 设置进度日志的最小输出间隔
+func SetProgressInterval(interval time.Duration) {
+	progressInterval.Store(int64(interval))
+}
+
+// Progress 返回批处理任务的进度上报函数
+// 上报函数最多每隔 progressInterval 输出一条 info 日志，包含 done/total 以及根据吞吐量估算的剩余时间
+// 完成(done >= total)时总会输出一条日志
+func Progress(ctx context.Context, total int) func(done int) {
+	entry := getBaseEntry(ctx, 1)
+	start := time.Now()
+	var (
+		mu       sync.Mutex
+		lastLog  time.Time
+		finished bool
+	)
+	return func(done int) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := time.Now()
+		complete := total > 0 && done >= total
+		if finished || (!complete && now.Sub(lastLog) < time.Duration(progressInterval.Load())) {
+			return
+		}
+		lastLog = now
+		finished = complete
+
+		elapsed := now.Sub(start)
+		e := entry.WithField("done", done).
+			WithField("total", total).
+			WithField("elapsed", elapsed.Round(time.Second).String())
+		if total > 0 {
+			e = e.WithField("percent", float64(done)*100/float64(total))
+		}
+		// 没有任何进展时无法估算剩余时间
+		if done > 0 && elapsed > 0 && total > done {
+			rate := float64(done) / elapsed.Seconds()
+			eta := time.Duration(float64(total-done) / rate * float64(time.Second))
+			e = e.WithField("eta", eta.Round(time.Second).String())
+		} else if !complete {
+			e = e.WithField("eta", "unknown")
+		}
+		if complete {
+			e.Info("progress completed")
+			return
+		}
+		e.Info("progress")
+	}
+}
@@ -0,0 +1,53 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// slowQueryThreshold 慢查询阈值，单位纳秒
+var slowQueryThreshold atomic.Int64
+
+func init() {
+	slowQueryThreshold.Store(int64(200 * time.Millisecond))
+}
+
+// SetSlowQueryThreshold 设置慢查询阈值
+func SetSlowQueryThreshold(threshold time.Duration) {
+	slowQueryThreshold.Store(int64(threshold))
+}
+
+// TimedQuery 记录查询耗时，返回的函数需要在查询结束时调用(通常使用 defer)
+// 超过慢查询阈值输出 warn 日志，否则输出 debug 日志
+//
+//	defer log.TimedQuery(ctx, "find_orders")()
+func TimedQuery(ctx context.Context, name string) func() {
+	entry := getBaseEntry(ctx, 1)
+	start := time.Now()
+	return func() {
+		duration := time.Since(start)
+		e := entry.WithField("query_name", name).
+			WithField("duration_ms", duration.Milliseconds())
+		if duration >= time.Duration(slowQueryThreshold.Load()) {
+			e.Warning("slow query")
+			return
+		}
+		e.Debug("query completed")
+	}
+}
+
+// LogQueryError 记录数据访问层的错误及出错的查询语句
+// 参数只记录占位符及类型(例如 $1:string)，不记录原始值，避免泄露个人信息
+func LogQueryError(ctx context.Context, query string, args []interface{}, err error) {
+	params := make([]string, len(args))
+	for i, arg := range args {
+		params[i] = fmt.Sprintf("$%d:%T", i+1, arg)
+	}
+	getBaseEntry(ctx, 1).
+		WithError(err).
+		WithField("query", query).
+		WithField("query_args", params).
+		Error("query failed")
+}
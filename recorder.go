@@ -0,0 +1,362 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open4go/log/model/operation"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// operationWriterQueueSize 异步写入队列长度
+	operationWriterQueueSize = 4096
+	// defaultOperationBatchSize 默认每批写入的记录数
+	defaultOperationBatchSize = 100
+	// defaultOperationFlushInterval 默认的批量写入间隔
+	defaultOperationFlushInterval = time.Second
+	// operationWriteTimeout 单次批量写入的超时时间
+	operationWriteTimeout = 10 * time.Second
+)
+
+// ErrOperationQueueFull 异步写入队列已满，记录被丢弃
+var ErrOperationQueueFull = errors.New("operation log queue is full")
+
+// OperationRecorder 以链式调用构建并写入一条操作日志
+//
+//	err := log.Operation(ctx).
+//		Method("PUT").
+//		Path("/orders/1").
+//		Target(order.ID).
+//		Before(old).
+//		After(order).
+//		Record()
+//
+// 客户端 IP、操作人、商户号、trace 从上下文中读取
+type OperationRecorder struct {
+	ctx context.Context
+	op  operation.Model
+	err error
+	// 修改前后的原始值，用于计算 Changes
+	before, after interface{}
+}
+
+// Operation 创建操作日志记录器
+func Operation(ctx context.Context) *OperationRecorder {
+	ctx = contextOrGLS(ctx)
+	r := &OperationRecorder{ctx: ctx}
+	r.op.ClientIP = stringFromContext(ctx, IPKey)
+	r.op.Operator = stringFromContext(ctx, OperatorKey)
+	r.op.Trace = stringFromContext(ctx, TraceIDKey)
+	r.op.Meta.MerchantID = stringFromContext(ctx, MerchantKey)
+	return r
+}
+
+// Method 请求方法/操作
+func (r *OperationRecorder) Method(method string) *OperationRecorder {
+	r.op.Method = method
+	return r
+}
+
+// Path 请求路径
+func (r *OperationRecorder) Path(path string) *OperationRecorder {
+	r.op.FullPath = path
+	return r
+}
+
+// Route 匹配的路由模板
+func (r *OperationRecorder) Route(route string) *OperationRecorder {
+	r.op.Route = route
+	return r
+}
+
+// RespCode 响应代码
+func (r *OperationRecorder) RespCode(code int) *OperationRecorder {
+	r.op.RespCode = code
+	return r
+}
+
+// Target 操作对象id
+func (r *OperationRecorder) Target(id string) *OperationRecorder {
+	r.op.TargetID = id
+	return r
+}
+
+// Device 设备号
+func (r *OperationRecorder) Device(device string) *OperationRecorder {
+	r.op.Device = device
+	return r
+}
+
+// User 用户id
+func (r *OperationRecorder) User(id string) *OperationRecorder {
+	r.op.UserID = id
+	return r
+}
+
+// Account 账号id
+func (r *OperationRecorder) Account(id string) *OperationRecorder {
+	r.op.AccountID = id
+	r.op.Meta.AccountID = id
+	return r
+}
+
+// Step 记录一个步骤的执行结果
+func (r *OperationRecorder) Step(name string, ok bool) *OperationRecorder {
+	r.op.AddStep(name, ok)
+	return r
+}
+
+// Before 修改前的数据，字符串原样记录，其它值序列化为 json
+// 同时设置 Before 和 After 的结构体/map 会计算字段级别的变更记录到 Changes
+func (r *OperationRecorder) Before(v interface{}) *OperationRecorder {
+	r.op.Before = r.encode(v)
+	r.before = v
+	return r
+}
+
+// After 修改后的数据，字符串原样记录，其它值序列化为 json
+func (r *OperationRecorder) After(v interface{}) *OperationRecorder {
+	r.op.After = r.encode(v)
+	r.after = v
+	return r
+}
+
+// encode 序列化修改前后的数据，保留第一个错误在 Record 时返回
+func (r *OperationRecorder) encode(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		if r.err == nil {
+			r.err = fmt.Errorf("encode operation state: %w", err)
+		}
+		return ""
+	}
+	return string(b)
+}
+
+// structured 返回值是否可以计算字段级别的变更
+func structured(v interface{}) bool {
+	switch v.(type) {
+	case nil, string, []byte:
+		return false
+	}
+	return true
+}
+
+// Record 写入操作日志
+// 通过 StartOperationWriter 启动异步写入后放入队列批量写入，否则与 AuditLog 相同同步写入
+// 两种方式都会写入全部写入目标并输出 audit_id
+func (r *OperationRecorder) Record() error {
+	if r.err != nil {
+		return r.err
+	}
+	op := r.op
+	if structured(r.before) && structured(r.after) {
+		if err := op.SetChanges(r.before, r.after); err != nil {
+			return fmt.Errorf("diff operation state: %w", err)
+		}
+	}
+	w := operationWriters.Load()
+	if w == nil {
+		return AuditLog(r.ctx, &op)
+	}
+	if !auditable(op.Method) {
+		return nil
+	}
+	prepareOperation(r.ctx, &op)
+	return w.enqueue(r.ctx, &op)
+}
+
+// operationWriters 当前运行的异步写入器
+var operationWriters atomic.Pointer[operationWriter]
+
+// queuedOperation 队列中的记录及发起写入的上下文，上下文用于输出日志
+type queuedOperation struct {
+	ctx context.Context
+	op  *operation.Model
+}
+
+// operationWriter 异步批量写入操作日志
+type operationWriter struct {
+	sink      *MongoSink
+	coll      *mongo.Collection
+	batchSize int
+	interval  time.Duration
+	queue     chan queuedOperation
+
+	// mu 保证停止后不再有记录进入队列
+	mu      sync.RWMutex
+	closed  bool
+	once    sync.Once
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// StartOperationWriter 启动操作日志的异步写入，记录批量写入 db 的 auth_operation_log 表
+// 并逐条写入 RegisterAuditSink 注册的写入目标，每 batchSize 条或每隔 interval 批量写入一次，小于等于 0 时使用默认值 100 条/1 秒
+// 返回的函数停止写入并等待队列中的记录全部写入，应在服务退出前调用；Fatal 退出时同样会自动写入
+// 再次调用时先停止之前启动的写入器
+func StartOperationWriter(db *mongo.Database, batchSize int, interval time.Duration) func() {
+	if batchSize <= 0 {
+		batchSize = defaultOperationBatchSize
+	}
+	if interval <= 0 {
+		interval = defaultOperationFlushInterval
+	}
+	w := &operationWriter{
+		sink:      &MongoSink{DB: db},
+		coll:      db.Collection((&operation.Model{}).CollectionName()),
+		batchSize: batchSize,
+		interval:  interval,
+		queue:     make(chan queuedOperation, operationWriterQueueSize),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go w.run()
+	// 再次调用时停止之前的写入器并等待其队列写入完成
+	if prev := operationWriters.Swap(w); prev != nil {
+		prev.stop()
+	}
+	stop := func() {
+		operationWriters.CompareAndSwap(w, nil)
+		w.stop()
+	}
+	RegisterExitHandler(stop)
+	return stop
+}
+
+// stop 停止写入并等待队列中的记录全部写入，可以重复调用
+func (w *operationWriter) stop() {
+	w.once.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		close(w.done)
+		<-w.stopped
+	})
+}
+
+// enqueue 将记录放入写入队列，队列满时丢弃
+func (w *operationWriter) enqueue(ctx context.Context, op *operation.Model) error {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		// 写入器已停止，回退到同步写入
+		return w.write([]queuedOperation{{ctx: ctx, op: op}})
+	}
+	defer w.mu.RUnlock()
+	select {
+	case w.queue <- queuedOperation{ctx: ctx, op: op}:
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "operation log queue is full, drop audit record %s\n", op.ID.Hex())
+		return ErrOperationQueueFull
+	}
+}
+
+// run 后台批量写入，停止时写入队列中剩余的记录
+func (w *operationWriter) run() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]queuedOperation, 0, w.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.write(batch); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write %d operation logs: %v\n", len(batch), err)
+		}
+		batch = make([]queuedOperation, 0, w.batchSize)
+	}
+	for {
+		select {
+		case item := <-w.queue:
+			batch = append(batch, item)
+			if len(batch) >= w.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-w.done:
+			for {
+				select {
+				case item := <-w.queue:
+					batch = append(batch, item)
+					if len(batch) >= w.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// write 批量写入 Mongo 后逐条写入其它写入目标，与 AuditLog 相同输出失败及成功的日志
+func (w *operationWriter) write(batch []queuedOperation) error {
+	docs := make([]interface{}, len(batch))
+	for i, item := range batch {
+		docs[i] = item.op
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), operationWriteTimeout)
+	defer cancel()
+	// 无序写入，单条失败不影响同批其它记录
+	_, err := w.coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	failed := insertManyFailures(err, len(batch))
+
+	errs := []error{err}
+	sinks := loadAuditSinks().others
+	for i, item := range batch {
+		// 写入其它目标时保留上下文中的值，但不受请求取消的影响
+		itemCtx, itemCancel := context.WithTimeout(context.WithoutCancel(item.ctx), operationWriteTimeout)
+		sinkErr := writeAuditSinks(item.ctx, itemCtx, sinks, item.op, 1)
+		itemCancel()
+		if failed[i] != nil {
+			auditFailed(item.ctx, w.sink, item.op, failed[i], 1)
+			continue
+		}
+		if sinkErr != nil {
+			errs = append(errs, sinkErr)
+			continue
+		}
+		auditWritten(item.ctx, item.op, 1)
+	}
+	return errors.Join(errs...)
+}
+
+// insertManyFailures 返回 InsertMany 中写入失败的记录下标及错误
+func insertManyFailures(err error, n int) map[int]error {
+	if err == nil {
+		return nil
+	}
+	failed := make(map[int]error)
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, we := range bulkErr.WriteErrors {
+			failed[we.Index] = we
+		}
+		return failed
+	}
+	for i := 0; i < n; i++ {
+		failed[i] = err
+	}
+	return failed
+}
@@ -0,0 +1,449 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// valuePattern 按值匹配的脱敏规则
+type valuePattern struct {
+	re   *regexp.Regexp
+	mask string
+	// valid 进一步校验匹配的内容，为空时全部替换
+	valid func(string) bool
+}
+
+var (
+	// 内置的常见敏感信息模式，默认不启用
+	// PatternCreditCard 银行卡号，只替换通过 Luhn 校验的数字，避免误伤订单号等长数字
+	PatternCreditCard = regexp.MustCompile(`\b(?:\d[ -]?){13,19}\b`)
+	// PatternJWT JWT token
+	PatternJWT = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+	// PatternBearer Authorization: Bearer xxx
+	PatternBearer = regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/=-]+`)
+	// PatternCNMobile 中国大陆手机号，保留前 3 位和后 4 位
+	PatternCNMobile = regexp.MustCompile(`\b(1[3-9]\d)\d{4}(\d{4})\b`)
+	// PatternCNIDCard 中国大陆 18 位身份证号，保留前 6 位和后 4 位
+	PatternCNIDCard = regexp.MustCompile(`\b(\d{6})\d{8}(\d{3}[\dXx])\b`)
+)
+
+// patternValidators 内置规则的附加校验
+var patternValidators = map[*regexp.Regexp]func(string) bool{
+	PatternCreditCard: luhnValid,
+}
+
+// Redactor 脱敏规则，Fields 与 Pattern 可以同时设置
+type Redactor struct {
+	// 字段名，不区分大小写，匹配的字段值整体替换为 Mask
+	// 同样作用于 WithField 传入的结构体/map 中嵌套的同名字段(按 json 名称匹配)
+	Fields []string
+	// 值匹配规则，字段值(包括嵌套的字符串)及日志信息中匹配的内容替换为 Mask
+	Pattern *regexp.Regexp
+	// 替换值，默认 ******，使用 Pattern 时可以引用分组，例如 $1****$2
+	Mask string
+}
+
+// valueRedactHook 扫描所有字段并替换敏感内容
+type valueRedactHook struct {
+	mu       sync.RWMutex
+	patterns []valuePattern
+	// fields 需要整体脱敏的字段名(小写)及替换值
+	fields map[string]string
+}
+
+var valueRedactor = &valueRedactHook{}
+
+// valueRedactOnce 第一次注册规则时才挂载 hook
+var valueRedactOnce sync.Once
+
+// RegisterValuePattern 注册按值匹配的脱敏规则
+// 所有字符串类型的字段值以及日志信息中匹配 regex 的内容会被替换为 mask
+// 用于捕获出现在意料之外字段中的敏感信息(例如 Before 中序列化的请求体)
+func RegisterValuePattern(regex *regexp.Regexp, mask string) {
+	valueRedactor.mu.Lock()
+	valueRedactor.patterns = append(valueRedactor.patterns, valuePattern{re: regex, mask: mask, valid: patternValidators[regex]})
+	valueRedactor.mu.Unlock()
+
+	valueRedactOnce.Do(func() {
+		AddHookWithPriority(valueRedactor, PriorityRedact)
+	})
+}
+
+// RegisterRedactor 注册脱敏规则，在日志离开进程前替换字段名或值匹配的敏感信息
+//
+//	log.RegisterRedactor(log.Redactor{Fields: []string{"password", "token"}})
+//	log.RegisterRedactor(log.Redactor{Pattern: log.PatternCNMobile, Mask: "$1****$2"})
+func RegisterRedactor(r Redactor) {
+	mask := r.Mask
+	if mask == "" {
+		mask = redactedValue
+	}
+	if len(r.Fields) > 0 {
+		valueRedactor.mu.Lock()
+		fields := make(map[string]string, len(valueRedactor.fields)+len(r.Fields))
+		for k, v := range valueRedactor.fields {
+			fields[k] = v
+		}
+		for _, name := range r.Fields {
+			fields[strings.ToLower(name)] = mask
+		}
+		valueRedactor.fields = fields
+		valueRedactor.mu.Unlock()
+	}
+	if r.Pattern != nil {
+		RegisterValuePattern(r.Pattern, mask)
+		return
+	}
+	valueRedactOnce.Do(func() {
+		AddHookWithPriority(valueRedactor, PriorityRedact)
+	})
+}
+
+// RegisterBuiltinValuePatterns 启用内置的脱敏规则(手机号、身份证号、银行卡号、JWT、Bearer token)
+// 身份证号需先于银行卡号匹配，避免被整体替换
+func RegisterBuiltinValuePatterns() {
+	RegisterValuePattern(PatternCNIDCard, "$1********$2")
+	RegisterValuePattern(PatternCNMobile, "$1****$2")
+	RegisterValuePattern(PatternCreditCard, redactedValue)
+	RegisterValuePattern(PatternJWT, redactedValue)
+	RegisterValuePattern(PatternBearer, "Bearer "+redactedValue)
+}
+
+// redactionConfigured 返回是否注册了脱敏规则
+func redactionConfigured() bool {
+	valueRedactor.mu.RLock()
+	defer valueRedactor.mu.RUnlock()
+	return len(valueRedactor.patterns) > 0 || len(valueRedactor.fields) > 0
+}
+
+// Levels 对所有级别生效
+func (h *valueRedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire 替换字段值中匹配的内容
+func (h *valueRedactHook) Fire(entry *logrus.Entry) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for k, v := range entry.Data {
+		if mask, ok := h.fields[strings.ToLower(k)]; ok {
+			entry.Data[k] = mask
+			continue
+		}
+		entry.Data[k] = h.redactValue(v)
+	}
+	entry.Message = h.redact(entry.Message)
+	return nil
+}
+
+func (h *valueRedactHook) redact(s string) string {
+	for _, p := range h.patterns {
+		if p.valid == nil {
+			s = p.re.ReplaceAllString(s, p.mask)
+			continue
+		}
+		s = p.re.ReplaceAllStringFunc(s, func(match string) string {
+			if !p.valid(match) {
+				return match
+			}
+			return p.re.ReplaceAllString(match, p.mask)
+		})
+	}
+	return s
+}
+
+// luhnValid 对匹配内容中的数字做 Luhn 校验
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+// maxRedactDepth 复合值逐层脱敏的最大深度，避免循环引用
+const maxRedactDepth = 32
+
+var (
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// redactValue 脱敏字段值
+// 结构体、map、切片等复合值通过反射逐层检查，不包含敏感内容时原样返回
+// 包含时按 json 字段名展开为 map/切片，只替换匹配的值，其它值保持原类型，输出与 JSONFormatter 一致
+func (h *valueRedactHook) redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return h.redact(val)
+	case error, json.Marshaler:
+		return v
+	}
+	if !composite(v) {
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	if !h.sensitive(rv, 0) {
+		return v
+	}
+	return h.rebuild(rv, 0)
+}
+
+// matches 返回字符串是否匹配任一规则
+func (h *valueRedactHook) matches(s string) bool {
+	for _, p := range h.patterns {
+		if p.valid == nil {
+			if p.re.MatchString(s) {
+				return true
+			}
+			continue
+		}
+		for _, match := range p.re.FindAllString(s, -1) {
+			if p.valid(match) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// opaque 返回值是否自行序列化，不再展开
+func opaque(v reflect.Value) bool {
+	t := v.Type()
+	return t.Implements(errorType) || t.Implements(marshalerType)
+}
+
+// sensitive 返回值中是否包含需要脱敏的字段或字符串
+func (h *valueRedactHook) sensitive(v reflect.Value, depth int) bool {
+	if depth > maxRedactDepth || !v.IsValid() {
+		return false
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() || opaque(v) {
+			return false
+		}
+		v = v.Elem()
+	}
+	if opaque(v) {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return h.matches(v.String())
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, _, ok := jsonField(t.Field(i))
+			if !ok {
+				continue
+			}
+			if _, masked := h.fields[strings.ToLower(name)]; masked && name != "" {
+				return true
+			}
+			if h.sensitive(v.Field(i), depth+1) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if _, masked := h.fields[strings.ToLower(mapKey(iter.Key()))]; masked {
+				return true
+			}
+			if h.sensitive(iter.Value(), depth+1) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+		for i := 0; i < v.Len(); i++ {
+			if h.sensitive(v.Index(i), depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rebuild 展开包含敏感内容的值并替换匹配的部分，不包含敏感内容的子值原样保留
+// 未导出的嵌入结构体中的字段无法直接取值，同样展开
+func (h *valueRedactHook) rebuild(v reflect.Value, depth int) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		if opaque(v) && v.CanInterface() {
+			return v.Interface()
+		}
+		v = v.Elem()
+	}
+	if v.CanInterface() && (depth > maxRedactDepth || opaque(v) || !h.sensitive(v, depth)) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return h.redact(v.String())
+	case reflect.Struct:
+		m := make(map[string]interface{}, v.NumField())
+		h.rebuildStruct(v, depth, m)
+		return m
+	case reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := mapKey(iter.Key())
+			if mask, ok := h.fields[strings.ToLower(k)]; ok {
+				m[k] = mask
+				continue
+			}
+			m[k] = h.rebuild(iter.Value(), depth+1)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = h.rebuild(v.Index(i), depth+1)
+		}
+		return s
+	}
+	return scalarValue(v)
+}
+
+// scalarValue 读取无法直接取值的基本类型
+func scalarValue(v reflect.Value) interface{} {
+	if v.CanInterface() {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return nil
+}
+
+// rebuildStruct 按 json 字段名展开结构体，匿名嵌入的结构体字段提升到外层
+func (h *valueRedactHook) rebuildStruct(v reflect.Value, depth int, m map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, omitEmpty, ok := jsonField(sf)
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		if omitEmpty && emptyValue(fv) {
+			continue
+		}
+		if name == "" {
+			// 未设置 json 名称的匿名结构体
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && !opaque(fv) {
+				h.rebuildStruct(fv, depth+1, m)
+			}
+			continue
+		}
+		if mask, masked := h.fields[strings.ToLower(name)]; masked {
+			m[name] = mask
+			continue
+		}
+		m[name] = h.rebuild(fv, depth+1)
+	}
+}
+
+// jsonField 返回结构体字段序列化后的名称，匿名结构体未设置名称时返回空字符串
+// ok 为 false 表示字段不会被序列化
+func jsonField(sf reflect.StructField) (name string, omitEmpty bool, ok bool) {
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	omitEmpty = strings.Contains(opts, "omitempty")
+	if sf.Anonymous && name == "" {
+		t := sf.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Struct {
+			return "", omitEmpty, true
+		}
+	}
+	if !sf.IsExported() {
+		return "", false, false
+	}
+	if name == "" {
+		name = sf.Name
+	}
+	return name, omitEmpty, true
+}
+
+// mapKey 返回 map 键序列化后的字符串
+func mapKey(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+// emptyValue 与 encoding/json 的 omitempty 判断一致
+func emptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// composite 返回值是否为可能嵌套敏感字段的复合类型
+func composite(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		// []byte 按 base64 输出，不展开
+		return !(t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package log
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+type redactBase struct {
+	Token string `json:"token"`
+}
+
+type redactOrder struct {
+	redactBase
+	ID     int64             `json:"id"`
+	Phone  string            `json:"phone"`
+	Remark string            `json:"remark,omitempty"`
+	Extra  map[string]string `json:"extra"`
+	secret string
+}
+
+func newTestRedactor() *valueRedactHook {
+	return &valueRedactHook{
+		patterns: []valuePattern{{re: regexp.MustCompile(`\b(1[3-9]\d)\d{4}(\d{4})\b`), mask: "$1****$2"}},
+		fields:   map[string]string{"token": redactedValue},
+	}
+}
+
+func TestRedactValueKeepsUnmatched(t *testing.T) {
+	type item struct {
+		ID    int64             `json:"id"`
+		Phone string            `json:"phone"`
+		Extra map[string]string `json:"extra"`
+	}
+	h := newTestRedactor()
+	v := &item{ID: math.MaxInt64, Phone: "none", Extra: map[string]string{"a": "b"}}
+	if got := h.redactValue(v); got != interface{}(v) {
+		t.Errorf("unmatched value replaced: %#v", got)
+	}
+}
+
+func TestRedactValueReplacesMatched(t *testing.T) {
+	h := newTestRedactor()
+	order := redactOrder{
+		redactBase: redactBase{Token: "abc"},
+		ID:         math.MaxInt64,
+		Phone:      "13812345678",
+		Extra:      map[string]string{"contact": "call 13912345678"},
+		secret:     "s",
+	}
+	got := h.redactValue(order)
+	want := map[string]interface{}{
+		"token": redactedValue,
+		"id":    int64(math.MaxInt64),
+		"phone": "138****5678",
+		"extra": map[string]interface{}{"contact": "call 139****5678"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestRedactValueCycle(t *testing.T) {
+	type node struct {
+		Name string `json:"name"`
+		Next *node  `json:"next"`
+	}
+	n := &node{Name: "13812345678"}
+	n.Next = n
+	h := newTestRedactor()
+	got, ok := h.redactValue(n).(map[string]interface{})
+	if !ok || got["name"] != "138****5678" {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func TestCreditCardLuhn(t *testing.T) {
+	h := &valueRedactHook{patterns: []valuePattern{{re: PatternCreditCard, mask: redactedValue, valid: luhnValid}}}
+	for in, want := range map[string]string{
+		"card 4111 1111 1111 1111 paid": "card " + redactedValue + " paid",
+		"card 4111-1111-1111-1111":      "card " + redactedValue,
+		"order 4111111111111112":        "order 4111111111111112",
+	} {
+		if got := h.redact(in); got != want {
+			t.Errorf("redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if h.matches("order 4111111111111112") {
+		t.Error("invalid card number matched")
+	}
+}
+
+func TestLogStructRedactWithOptions(t *testing.T) {
+	type payment struct {
+		Card   string `log:"card,omitempty,redact"`
+		Amount int    `log:"amount,omitempty"`
+		Holder string `log:"holder, redact"`
+	}
+	data := LogStruct(context.Background(), payment{Card: "4111111111111111", Amount: 100, Holder: "alice"}).Data
+	if data["card"] != redactedValue || data["holder"] != redactedValue {
+		t.Errorf("options not parsed: %v", data)
+	}
+	if data["amount"] != 100 {
+		t.Errorf("amount changed: %v", data["amount"])
+	}
+}
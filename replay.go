@@ -0,0 +1,125 @@
+package log
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"sync/atomic"
+)
+
+// maxReplayEntries 每个请求最多缓存的低级别日志数量
+const maxReplayEntries = 200
+
+// replayedKey 标记重放的日志
+const replayedKey = "replayed"
+
+// replayEnabled 是否开启错误触发的日志重放
+var replayEnabled atomic.Bool
+
+// outputLevel 通过 Init/SetLevel 设置的主输出级别，默认与 logrus 相同为 info
+// 输出目标级别更低或开启重放后携带重放缓存的请求日志会创建更低级别的日志，低于 outputLevel 的日志由 safeFormatter 拦截
+var outputLevel atomic.Uint32
+
+// replayLogger debug 级别的 logger，与全局日志共用输出、格式及 hook
+// 只有携带重放缓存的请求日志通过它创建，全局 logger 保持实际级别，其它日志不会因开启重放而产生 debug 开销
+var replayLogger = newChildLogger(logrus.DebugLevel)
+
+func init() {
+	outputLevel.Store(uint32(logrus.InfoLevel))
+}
+
+// replayBuffer 请求期间被拦截的低级别日志
+type replayBuffer struct {
+	mu      sync.Mutex
+	entries []*logrus.Entry
+}
+
+// enableReplay 开启错误触发的日志重放，由 RequestLogger 的 WithVerboseReplay 选项调用
+func enableReplay() {
+	replayEnabled.Store(true)
+}
+
+// replayActive 返回上下文是否携带重放缓存，此时低于输出级别的日志需要创建并缓存
+func replayActive(ctx context.Context) bool {
+	return replayEnabled.Load() && replayFrom(contextOrGLS(ctx)) != nil
+}
+
+// replayEntry 上下文携带重放缓存时将日志条目切换到 debug 级别的 replayLogger
+// 单独设置了级别的模块按模块级别输出，不切换
+func replayEntry(entry *logrus.Entry, ctx context.Context) {
+	if !replayEnabled.Load() || replayFrom(ctx) == nil || hasLevelOverride(entry) {
+		return
+	}
+	if !entry.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		entry.Logger = replayLogger
+	}
+}
+
+// withReplayBuffer 在上下文中创建请求的重放缓存
+func withReplayBuffer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayKey, &replayBuffer{})
+}
+
+// replayFrom 返回上下文中的重放缓存
+func replayFrom(ctx context.Context) *replayBuffer {
+	if ctx == nil {
+		return nil
+	}
+	rb, _ := ctx.Value(replayKey).(*replayBuffer)
+	return rb
+}
+
+// suppressed 判断日志是否低于主输出级别，是则跳过主输出
+// 开启重放时缓存到请求的重放缓存中，单独设置了级别的模块不缓存
+func suppressed(entry *logrus.Entry) bool {
+	if entry.Level <= entryOutputLevel(entry) {
+		return false
+	}
+	if _, ok := entry.Data[replayedKey]; ok {
+		return false
+	}
+	if replayEnabled.Load() && !hasLevelOverride(entry) {
+		if rb := replayFrom(entry.Context); rb != nil {
+			rb.add(entry)
+		}
+	}
+	return true
+}
+
+func (rb *replayBuffer) add(entry *logrus.Entry) {
+	data := make(logrus.Fields, len(entry.Data)+1)
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	data[replayedKey] = true
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if len(rb.entries) >= maxReplayEntries {
+		return
+	}
+	rb.entries = append(rb.entries, &logrus.Entry{
+		Logger:  entry.Logger,
+		Data:    data,
+		Time:    entry.Time,
+		Level:   entry.Level,
+		Message: entry.Message,
+	})
+}
+
+// flush 以原始级别和时间输出缓存的日志
+// 日志创建时已经过 hook，重放时直接格式化后写入主输出，不再触发 hook
+func (rb *replayBuffer) flush() {
+	rb.mu.Lock()
+	entries := rb.entries
+	rb.entries = nil
+	rb.mu.Unlock()
+
+	for _, e := range entries {
+		b, err := parentFormatter{}.Format(e)
+		if err != nil || len(b) == 0 {
+			continue
+		}
+		_, _ = parentOutput{}.Write(b)
+	}
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestReplayKeepsProcessLevel(t *testing.T) {
+	buf := captureOutput(t)
+	enableReplay()
+	t.Cleanup(func() { replayEnabled.Store(false) })
+	if err := SetLevel("info"); err != nil {
+		t.Fatal(err)
+	}
+	if logger.GetLevel() != logrus.InfoLevel {
+		t.Errorf("logger level raised to %s", logger.GetLevel())
+	}
+
+	// 未携带重放缓存的日志不会以 debug 级别创建
+	if Log(context.Background()).Logger.IsLevelEnabled(logrus.DebugLevel) {
+		t.Error("entry without replay buffer created at debug level")
+	}
+
+	ctx := withReplayBuffer(context.Background())
+	Log(ctx).Debug("buffered debug")
+	if strings.Contains(buf.String(), "buffered debug") {
+		t.Fatal("debug emitted before replay")
+	}
+	replayFrom(ctx).flush()
+	if !strings.Contains(buf.String(), "buffered debug") {
+		t.Errorf("debug not replayed: %s", buf.String())
+	}
+}
+
+// countHook 记录触发次数
+type countHook struct{ fired int }
+
+func (h *countHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *countHook) Fire(*logrus.Entry) error {
+	h.fired++
+	return nil
+}
+
+func TestReplaySkipsHooks(t *testing.T) {
+	buf := captureOutput(t)
+	enableReplay()
+	t.Cleanup(func() { replayEnabled.Store(false) })
+	if err := SetLevel("info"); err != nil {
+		t.Fatal(err)
+	}
+	h := &countHook{}
+	AddHookWithPriority(h, PriorityDefault)
+	t.Cleanup(func() { removeHook(h) })
+
+	ctx := withReplayBuffer(context.Background())
+	Log(ctx).Debug("buffered debug")
+	fired := h.fired
+	replayFrom(ctx).flush()
+	if !strings.Contains(buf.String(), "buffered debug") {
+		t.Errorf("debug not replayed: %s", buf.String())
+	}
+	if h.fired != fired {
+		t.Errorf("replay fired hooks %d times", h.fired-fired)
+	}
+}
@@ -0,0 +1,151 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// reporterQueueSize 上报队列长度，队列满时丢弃
+	reporterQueueSize = 256
+	// webhookTimeout webhook 请求超时时间
+	webhookTimeout = 5 * time.Second
+)
+
+// ErrorEvent 上报的错误事件
+type ErrorEvent struct {
+	Fingerprint string                 `json:"fingerprint"`
+	Level       string                 `json:"level"`
+	Message     string                 `json:"message"`
+	Error       string                 `json:"error,omitempty"`
+	Time        time.Time              `json:"time"`
+	Stacktrace  interface{}            `json:"stacktrace,omitempty"`
+	Fields      map[string]interface{} `json:"fields"`
+}
+
+// ErrorReporter 错误上报，内置 WebhookReporter，也可以接入 Sentry 等告警平台，例如:
+//
+//	type SentryReporter struct{ Hub *sentry.Hub }
+//
+//	func (r *SentryReporter) Report(ctx context.Context, e log.ErrorEvent) error {
+//		r.Hub.WithScope(func(scope *sentry.Scope) {
+//			scope.SetFingerprint([]string{e.Fingerprint})
+//			scope.SetExtras(e.Fields)
+//			r.Hub.CaptureMessage(e.Message)
+//		})
+//		return nil
+//	}
+//
+// 在后台协程中调用，实现需要自行控制超时
+type ErrorReporter interface {
+	Report(ctx context.Context, event ErrorEvent) error
+}
+
+// reporterHook 将带指纹的错误日志放入上报队列
+type reporterHook struct {
+	reporter ErrorReporter
+	queue    chan ErrorEvent
+}
+
+var reporterOnce sync.Once
+
+// SetErrorReporter 设置错误上报，只能设置一次，在 Init 之后调用
+// ErrorWithStack 等输出的带指纹的错误会连同日志中的全部字段一起上报，
+// 按指纹被限流(SetErrorRateLimit)的错误不上报，避免告警风暴
+func SetErrorReporter(reporter ErrorReporter) {
+	reporterOnce.Do(func() {
+		h := &reporterHook{
+			reporter: reporter,
+			queue:    make(chan ErrorEvent, reporterQueueSize),
+		}
+		go h.run()
+		AddHookWithPriority(h, PriorityPersist)
+	})
+}
+
+// Levels 仅上报 error 及以上级别
+func (h *reporterHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+// Fire 转换为错误事件并放入队列
+func (h *reporterHook) Fire(entry *logrus.Entry) error {
+	fp, ok := entry.Data[fingerprintKey].(string)
+	if !ok || rateLimited(entry) {
+		return nil
+	}
+	event := ErrorEvent{
+		Fingerprint: fp,
+		Level:       entry.Level.String(),
+		Message:     entry.Message,
+		Time:        entry.Time,
+		Fields:      make(map[string]interface{}, len(entry.Data)),
+	}
+	for k, v := range entry.Data {
+		switch k {
+		case logrus.ErrorKey:
+			if err, ok := v.(error); ok {
+				event.Error = err.Error()
+			}
+		case "stacktrace":
+			event.Stacktrace = v
+		default:
+			event.Fields[k] = safeValue(v)
+		}
+	}
+	select {
+	case h.queue <- event:
+	default:
+		fmt.Fprintf(os.Stderr, "error report queue is full, drop %s\n", fp)
+	}
+	return nil
+}
+
+// run 后台上报
+func (h *reporterHook) run() {
+	for event := range h.queue {
+		if err := h.reporter.Report(context.Background(), event); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to report error %s: %v\n", event.Fingerprint, err)
+		}
+	}
+}
+
+// WebhookReporter 以 json POST 错误事件到 webhook
+type WebhookReporter struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookReporter 创建 webhook 上报
+func NewWebhookReporter(url string) *WebhookReporter {
+	return &WebhookReporter{URL: url, Client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// Report 实现 ErrorReporter
+func (r *WebhookReporter) Report(ctx context.Context, event ErrorEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
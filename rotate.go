@@ -0,0 +1,158 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotateTimeFormat 轮转文件名中的时间格式
+const rotateTimeFormat = "20060102T150405.000"
+
+// RotatingFile 按大小轮转的日志文件
+// 当前文件超过 maxSize 后重命名为 name-<时间>-<序号>.ext 并创建新文件，
+// 超过 maxAge 或超出 maxBackups 个数的轮转文件会被删除
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// seq 轮转序号，避免同一毫秒内多次轮转覆盖之前的文件
+	seq int
+}
+
+// NewRotatingFile 打开或创建日志文件，maxSize 为字节数，小于等于 0 时不轮转
+func NewRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*RotatingFile, error) {
+	if path == "" {
+		return nil, errors.New("log file path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f := &RotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write 写入日志，超出大小时先轮转
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close 关闭当前文件
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open 以追加方式打开日志文件
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate 重命名当前文件并创建新文件
+// 重命名失败时重新打开原文件继续写入，不会导致之后的写入全部失败
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		f.file = nil
+		return errors.Join(err, f.open())
+	}
+	f.file = nil
+	ext := filepath.Ext(f.path)
+	f.seq = (f.seq + 1) % 10000
+	backup := fmt.Sprintf("%s-%s-%04d%s", strings.TrimSuffix(f.path, ext), time.Now().Format(rotateTimeFormat), f.seq, ext)
+	if err := os.Rename(f.path, backup); err != nil {
+		return errors.Join(err, f.open())
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.cleanup()
+	return nil
+}
+
+// cleanup 删除过期及超出数量的轮转文件
+func (f *RotatingFile) cleanup() {
+	if f.maxAge <= 0 && f.maxBackups <= 0 {
+		return
+	}
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext) + "-"
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return
+	}
+	// 只处理由轮转产生的文件
+	var backups []string
+	for _, name := range matches {
+		if rotatedName(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)) {
+			backups = append(backups, name)
+		}
+	}
+	// 文件名中的时间及序号可以按字典序排序，最新的在前
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	for i, name := range backups {
+		expired := false
+		if f.maxBackups > 0 && i >= f.maxBackups {
+			expired = true
+		}
+		if f.maxAge > 0 {
+			if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > f.maxAge {
+				expired = true
+			}
+		}
+		if expired {
+			_ = os.Remove(name)
+		}
+	}
+}
+
+// rotatedName 返回文件名中的 <时间>-<序号> 是否由轮转产生
+func rotatedName(stamp string) bool {
+	stamp, seq, ok := strings.Cut(stamp, "-")
+	if !ok || len(seq) != 4 || strings.Trim(seq, "0123456789") != "" {
+		return false
+	}
+	_, err := time.Parse(rotateTimeFormat, stamp)
+	return err == nil
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+)
+
+// safeFormatter 包装真正的格式化器
+// 字段值的 String()/Error()/MarshalJSON() 发生 panic 时不会导致日志调用崩溃
+// 而是将出问题的字段替换为占位符后重新格式化
+type safeFormatter struct {
+	logrus.Formatter
+	// unfiltered 输出目标(sink)使用，重放及采样只由主输出判断
+	unfiltered bool
+}
+
+// Format 格式化日志，出现 panic 时降级处理
+func (f *safeFormatter) Format(entry *logrus.Entry) (b []byte, err error) {
+	// 低于主输出级别的日志(为输出目标或重放创建)不输出，开启错误触发重放时先缓存
+	// 开启错误采样时，被采样丢弃的错误不输出
+	if !f.unfiltered && (suppressed(entry) || sampledOut(entry)) {
+		return nil, nil
+	}
+	// 被限流的错误任何输出都跳过
+	if rateLimited(entry) {
+		return nil, nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			b, err = f.formatSanitized(entry)
+		}
+		// 只统计主输出，同一行写入多个输出目标时不重复计数
+		if err == nil && !f.unfiltered {
+			recordLineSize(len(b))
+		}
+	}()
+	return f.Formatter.Format(entry)
+}
+
+// formatSanitized 逐个检查字段，替换无法序列化的值后再次格式化
+func (f *safeFormatter) formatSanitized(entry *logrus.Entry) (b []byte, err error) {
+	data := make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		data[k] = safeValue(v)
+	}
+	clone := *entry
+	clone.Data = data
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("format log entry: %v", r)
+		}
+	}()
+	return f.Formatter.Format(&clone)
+}
+
+// safeValue 返回可以安全序列化的值
+// 如果值本身序列化会 panic 则返回 <unmarshalable: ...> 占位符
+func safeValue(v interface{}) (out interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			out = fmt.Sprintf("<unmarshalable: %v>", r)
+		}
+	}()
+	switch val := v.(type) {
+	case error:
+		// JSONFormatter 会将 error 转换为 Error() 字符串
+		return val.Error()
+	case fmt.Stringer:
+		_ = val.String()
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprintf("<unmarshalable: %v>", err)
+	}
+	return v
+}
@@ -0,0 +1,68 @@
+package log
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// panicStringer String() 发生 panic
+type panicStringer struct{}
+
+func (panicStringer) String() string { panic("string boom") }
+
+// panicMarshaler MarshalJSON() 发生 panic
+type panicMarshaler struct{ ID int }
+
+func (panicMarshaler) MarshalJSON() ([]byte, error) { panic("marshal boom") }
+
+func formatEntry(t *testing.T, formatter logrus.Formatter, value interface{}) string {
+	t.Helper()
+	entry := logrus.NewEntry(logrus.New()).WithField("bad", value).WithField("ok", "kept")
+	entry.Level = logrus.InfoLevel
+	entry.Message = "hello"
+	b, err := (&safeFormatter{Formatter: formatter}).Format(entry)
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	return string(b)
+}
+
+func TestSafeFormatterJSON(t *testing.T) {
+	out := formatEntry(t, &logrus.JSONFormatter{}, panicMarshaler{ID: 1})
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &data); err != nil {
+		t.Fatalf("invalid json %q: %v", out, err)
+	}
+	if data["bad"] != "<unmarshalable: marshal boom>" {
+		t.Errorf("placeholder missing: %s", out)
+	}
+	if data["ok"] != "kept" || data["msg"] != "hello" {
+		t.Errorf("other fields lost: %s", out)
+	}
+}
+
+func TestSafeFormatterText(t *testing.T) {
+	// TextFormatter 通过 fmt 输出字段，fmt 会捕获 String() 的 panic 并输出 PANIC 占位
+	out := formatEntry(t, &logrus.TextFormatter{DisableColors: true}, panicStringer{})
+	if !strings.Contains(out, "PANIC=String method: string boom") {
+		t.Errorf("placeholder missing: %s", out)
+	}
+	if !strings.Contains(out, "ok=kept") || !strings.Contains(out, "msg=hello") {
+		t.Errorf("other fields lost: %s", out)
+	}
+}
+
+func TestSafeValue(t *testing.T) {
+	if v := safeValue(panicStringer{}); v != "<unmarshalable: string boom>" {
+		t.Errorf("stringer: %v", v)
+	}
+	if v := safeValue(panicMarshaler{}); v != "<unmarshalable: marshal boom>" {
+		t.Errorf("marshaler: %v", v)
+	}
+	if v := safeValue(42); v != 42 {
+		t.Errorf("plain value changed: %v", v)
+	}
+}
@@ -0,0 +1,65 @@
+package log
+
+import (
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// maxSampledFingerprints 采样状态最多保留的指纹数量
+const maxSampledFingerprints = 10000
+
+// errorSampler 按错误指纹采样
+// 每个窗口内每个指纹的第一次出现总会输出，之后相同的错误每 every 次输出一次
+type errorSampler struct {
+	mu     sync.Mutex
+	window time.Duration
+	every  int
+	seen   map[string]*sampleState
+}
+
+// sampleState 单个指纹在当前窗口内的状态
+type sampleState struct {
+	start time.Time
+	count int
+}
+
+var sampler = &errorSampler{}
+
+// SetErrorSampling 开启错误日志采样
+// 在每个 window 内同一指纹的错误第一次出现时完整输出，之后每 every 次输出一次，
+// 保证每种错误至少有一个完整样例的同时控制日志量。every <= 1 时关闭采样
+func SetErrorSampling(window time.Duration, every int) {
+	sampler.mu.Lock()
+	defer sampler.mu.Unlock()
+	sampler.window = window
+	sampler.every = every
+	sampler.seen = make(map[string]*sampleState)
+}
+
+// sampledOut 判断错误日志是否被采样丢弃
+func sampledOut(entry *logrus.Entry) bool {
+	if entry.Level != logrus.ErrorLevel {
+		return false
+	}
+	return sampler.drop(fingerprint(entry), entry.Time)
+}
+
+func (s *errorSampler) drop(fp string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.every <= 1 {
+		return false
+	}
+
+	state, ok := s.seen[fp]
+	if !ok || now.Sub(state.start) >= s.window {
+		if !ok && len(s.seen) >= maxSampledFingerprints {
+			s.seen = make(map[string]*sampleState)
+		}
+		s.seen[fp] = &sampleState{start: now, count: 1}
+		return false
+	}
+	state.count++
+	return (state.count-1)%s.every != 0
+}
@@ -0,0 +1,187 @@
+package log
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// levelWriter 按日志级别写入的输出目标，例如 syslog 需要将级别映射为优先级
+type levelWriter interface {
+	WriteLevel(level logrus.Level, p []byte) error
+}
+
+// sinkHook 将日志按级别及格式写入额外的输出目标
+type sinkHook struct {
+	mu        sync.Mutex
+	out       io.Writer
+	levels    []logrus.Level
+	formatter logrus.Formatter
+}
+
+// AddSink 增加一个输出目标，与 Init 设置的主输出相互独立
+// 只输出 minLevel 及以上级别的日志，formatter 为 nil 时使用 json 格式；在 Init 之后调用
+//
+//	log.Init("debug", os.Stdout)
+//	file, _ := log.NewRotatingFile("/var/log/app.log", 100<<20, 7*24*time.Hour, 10)
+//	log.AddSink(file, logrus.InfoLevel, &logrus.JSONFormatter{})
+//	log.AddSink(remote, logrus.ErrorLevel, nil)
+//
+// 输出目标在脱敏之后写入，不受错误采样影响
+// 输出目标与主输出的级别相互独立，例如全局为 info 时 minLevel 为 debug 的输出目标同样输出 debug 日志，
+// 此时 debug 日志会被创建并经过 hook，主输出仍只输出 info 及以上级别
+func AddSink(out io.Writer, minLevel logrus.Level, formatter logrus.Formatter) {
+	addSink(out, minLevel, formatter)
+}
+
+// addSink 增加输出目标并降低 logrus 实例的级别
+func addSink(out io.Writer, minLevel logrus.Level, formatter logrus.Formatter) *sinkHook {
+	if formatter == nil {
+		formatter = &logrus.JSONFormatter{}
+	}
+	var levels []logrus.Level
+	for _, level := range logrus.AllLevels {
+		if level <= minLevel {
+			levels = append(levels, level)
+		}
+	}
+	h := &sinkHook{
+		out:       out,
+		levels:    levels,
+		formatter: &safeFormatter{Formatter: formatter, unfiltered: true},
+	}
+	AddHookWithPriority(h, PriorityPersist)
+	lowerSinkLevel(minLevel)
+	return h
+}
+
+// Levels 输出目标的级别
+func (h *sinkHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire 格式化并写入输出目标
+func (h *sinkHook) Fire(entry *logrus.Entry) error {
+	b, err := h.formatter.Format(entry)
+	if err != nil || len(b) == 0 {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if lw, ok := h.out.(levelWriter); ok {
+		return lw.WriteLevel(entry.Level, b)
+	}
+	_, err = h.out.Write(b)
+	return err
+}
+
+// SinkConfig 输出目标配置，对应 log.sinks 中的一项
+type SinkConfig struct {
+	// stdout, stderr, file, unix, syslog
+	Type string `mapstructure:"type"`
+	// 最低输出级别，默认 info，与全局级别相互独立
+	Level string `mapstructure:"level"`
+	// 输出格式 json/text/cef/gcp，默认 json
+	Format string `mapstructure:"format"`
+	// file: 文件路径; unix: socket 路径
+	Path string `mapstructure:"path"`
+	// file: 单个文件最大 MB，默认 100
+	MaxSizeMB int64 `mapstructure:"max_size_mb"`
+	// file: 轮转文件保留时长，例如 168h，0 表示不按时间清理
+	MaxAge time.Duration `mapstructure:"max_age"`
+	// file: 最多保留的轮转文件数，0 表示不按数量清理
+	MaxBackups int `mapstructure:"max_backups"`
+	// syslog: 网络(udp/tcp)及地址，为空时写入本机 syslog
+	Network string `mapstructure:"network"`
+	Address string `mapstructure:"address"`
+	// syslog: tag，默认 server.name
+	Tag string `mapstructure:"tag"`
+}
+
+// ConfigureSinks 读取 log.sinks 配置并增加输出目标，在 Init 之后调用，例如:
+//
+//	log:
+//	  sinks:
+//	    - type: file
+//	      path: /var/log/app.log
+//	      max_size_mb: 100
+//	      max_age: 168h
+//	      max_backups: 10
+//	    - type: syslog
+//	      level: error
+//	      network: udp
+//	      address: syslog.internal:514
+func ConfigureSinks() error {
+	var configs []SinkConfig
+	if err := viper.UnmarshalKey("log.sinks", &configs); err != nil {
+		return fmt.Errorf("invalid log.sinks: %w", err)
+	}
+	for i, cfg := range configs {
+		if err := addConfiguredSink(cfg); err != nil {
+			return fmt.Errorf("log.sinks[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// addConfiguredSink 按配置创建并增加输出目标
+func addConfiguredSink(cfg SinkConfig) error {
+	level := logrus.InfoLevel
+	if cfg.Level != "" {
+		l, ok := levels[cfg.Level]
+		if !ok {
+			return fmt.Errorf("unknown log level: %s", cfg.Level)
+		}
+		level = l
+	}
+	format := cfg.Format
+	if format == "" {
+		format = "json"
+	}
+	newFormatter, ok := formatters[format]
+	if !ok {
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+
+	var out io.Writer
+	switch cfg.Type {
+	case "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	case "file":
+		maxSize := cfg.MaxSizeMB
+		if maxSize <= 0 {
+			maxSize = 100
+		}
+		file, err := NewRotatingFile(cfg.Path, maxSize<<20, cfg.MaxAge, cfg.MaxBackups)
+		if err != nil {
+			return err
+		}
+		out = file
+	case "unix":
+		w, err := NewUnixSocketWriter(cfg.Path)
+		if err != nil {
+			return err
+		}
+		out = w
+	case "syslog":
+		tag := cfg.Tag
+		if tag == "" {
+			tag = viper.GetString("server.name")
+		}
+		w, err := dialSyslog(cfg.Network, cfg.Address, tag)
+		if err != nil {
+			return err
+		}
+		out = w
+	default:
+		return fmt.Errorf("unknown sink type: %s", cfg.Type)
+	}
+	AddSink(out, level, newFormatter())
+	return nil
+}
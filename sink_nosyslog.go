@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package log
+
+import (
+	"errors"
+	"io"
+)
+
+// dialSyslog 当前平台不支持 syslog
+func dialSyslog(network, address, tag string) (io.Writer, error) {
+	return nil, errors.New("syslog sink is not supported on this platform")
+}
@@ -0,0 +1,40 @@
+//go:build !windows && !plan9
+
+package log
+
+import (
+	"github.com/sirupsen/logrus"
+	"io"
+	"log/syslog"
+)
+
+// syslogWriter 按日志级别写入对应的 syslog 优先级
+type syslogWriter struct {
+	*syslog.Writer
+}
+
+// dialSyslog 连接 syslog，network 为空时写入本机 syslog
+func dialSyslog(network, address, tag string) (io.Writer, error) {
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogWriter{Writer: w}, nil
+}
+
+// WriteLevel 实现 levelWriter
+func (w *syslogWriter) WriteLevel(level logrus.Level, p []byte) error {
+	msg := string(p)
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return w.Crit(msg)
+	case logrus.ErrorLevel:
+		return w.Err(msg)
+	case logrus.WarnLevel:
+		return w.Warning(msg)
+	case logrus.InfoLevel:
+		return w.Info(msg)
+	default:
+		return w.Debug(msg)
+	}
+}
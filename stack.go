@@ -0,0 +1,182 @@
+package log
+
+import (
+	"context"
+	"errors"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultStackDepth 默认最多保留的堆栈帧数
+const defaultStackDepth = 20
+
+var (
+	// stackDepth 堆栈最多保留的帧数
+	stackDepth atomic.Int32
+	// structuredStack 是否以结构化形式输出 stacktrace 字段
+	structuredStack atomic.Bool
+)
+
+func init() {
+	stackDepth.Store(defaultStackDepth)
+}
+
+// SetStackDepth 设置 stacktrace 最多保留的帧数，默认 20，小于等于 0 时恢复默认值
+// 跳过日志包自身、logrus 及 runtime 的帧后再计算
+func SetStackDepth(depth int) {
+	if depth <= 0 {
+		depth = defaultStackDepth
+	}
+	stackDepth.Store(int32(depth))
+}
+
+// SetStructuredStack 设置 stacktrace 字段是否输出为 []StackFrame，默认输出为字符串
+// 结构化形式便于在日志平台中按函数或文件检索
+func SetStructuredStack(enabled bool) {
+	structuredStack.Store(enabled)
+}
+
+// StackFrame 结构化的堆栈帧
+type StackFrame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// stackTracer pkg/errors 风格的携带堆栈的错误
+type stackTracer interface {
+	StackTrace() pkgerrors.StackTrace
+}
+
+// ErrorFields 携带结构化字段的错误
+// ErrorWithStack/FatalWithStack 会自动将 Fields() 返回的字段合并到日志中,
+// 例如失败的订单号，调用方无需重复指定
+// 与日志已有字段或 error、stacktrace 等保留字段同名的字段加 err_ 前缀，不会覆盖原字段
+//
+//	type OrderError struct{ OrderID string }
+//	func (e *OrderError) Error() string { return "order failed" }
+//	func (e *OrderError) Fields() map[string]interface{} {
+//		return map[string]interface{}{"order_id": e.OrderID}
+//	}
+type ErrorFields interface {
+	Fields() map[string]interface{}
+}
+
+// ErrorWithStack 输出带有堆栈信息的错误日志
+// 未传入 args 时使用 err.Error() 作为日志信息
+func ErrorWithStack(ctx context.Context, err error, args ...interface{}) {
+	entry := withErrorDetails(getBaseEntry(ctx, 1), err)
+	if len(args) == 0 && err != nil {
+		args = []interface{}{err.Error()}
+	}
+	entry.Error(args...)
+}
+
+// ErrorfWithStack 输出带有堆栈信息的格式化错误日志，同时记录 msg_template
+func ErrorfWithStack(ctx context.Context, err error, format string, args ...interface{}) {
+	withErrorDetails(getBaseEntry(ctx, 1), err).
+		WithField(msgTemplateKey, format).
+		Errorf(format, args...)
+}
+
+// errorFieldPrefix 与保留字段同名的错误字段的前缀
+const errorFieldPrefix = "err_"
+
+// reservedErrorKeys withErrorDetails 及 formatter 使用的字段
+var reservedErrorKeys = map[string]bool{
+	logrus.ErrorKey:      true,
+	"stacktrace":         true,
+	fingerprintKey:       true,
+	"suppressed_count":   true,
+	msgTemplateKey:       true,
+	logrus.FieldKeyMsg:   true,
+	logrus.FieldKeyLevel: true,
+	logrus.FieldKeyTime:  true,
+}
+
+// withErrorDetails 附加错误、堆栈以及错误自带的字段
+func withErrorDetails(entry *logrus.Entry, err error) *logrus.Entry {
+	var ef ErrorFields
+	if errors.As(err, &ef) {
+		entry = entry.WithFields(errorFields(entry, ef.Fields()))
+	}
+	frames := stackFrames(err)
+	entry = entry.WithError(err).
+		WithField("stacktrace", getStackTrace(frames))
+	return withFingerprint(entry, err, frames)
+}
+
+// errorFields 为与已有字段或保留字段同名的错误字段加前缀
+func errorFields(entry *logrus.Entry, fields map[string]interface{}) logrus.Fields {
+	out := make(logrus.Fields, len(fields))
+	for k, v := range fields {
+		for {
+			if _, exists := entry.Data[k]; !exists && !reservedErrorKeys[k] {
+				break
+			}
+			k = errorFieldPrefix + k
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// getStackTrace 获取堆栈信息
+// 开启 SetStructuredStack 时返回 []StackFrame，否则返回字符串
+func getStackTrace(frames []StackFrame) interface{} {
+	if structuredStack.Load() {
+		return frames
+	}
+	b := &strings.Builder{}
+	for _, f := range frames {
+		b.WriteString(f.Func)
+		b.WriteString("\n\t")
+		b.WriteString(f.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(f.Line))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// stackFrames 解析堆栈，跳过内部帧并按 stackDepth 截断
+// 如果错误本身携带了堆栈(pkg/errors)，优先使用错误产生处的堆栈
+// 否则使用当前调用处的堆栈
+func stackFrames(err error) []StackFrame {
+	var pcs []uintptr
+	var st stackTracer
+	if errors.As(err, &st) {
+		// pkg/errors 的 Frame 与 runtime.Callers 返回的程序计数器一致
+		trace := st.StackTrace()
+		pcs = make([]uintptr, len(trace))
+		for i, f := range trace {
+			pcs[i] = uintptr(f)
+		}
+	} else {
+		pcs = make([]uintptr, 64)
+		// 跳过 runtime.Callers 及 stackFrames 自身
+		pcs = pcs[:runtime.Callers(2, pcs)]
+	}
+
+	depth := int(stackDepth.Load())
+	out := make([]StackFrame, 0, depth)
+	frames := runtime.CallersFrames(pcs)
+	for len(out) < depth {
+		frame, more := frames.Next()
+		if frame.Function != "" && !internalFrame(frame.Function) {
+			out = append(out, StackFrame{
+				Func: frame.Function,
+				File: shortFile(frame.File),
+				Line: frame.Line,
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,46 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fieldsError 携带与保留字段同名字段的错误
+type fieldsError struct{}
+
+func (fieldsError) Error() string { return "order failed" }
+
+func (fieldsError) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"order_id":   "o-1",
+		"trace":      "from-error",
+		"error":      "shadow",
+		"stacktrace": "fake",
+	}
+}
+
+func TestErrorFieldsDoNotOverwriteReserved(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "t-1")
+	entry := withErrorDetails(getBaseEntry(ctx, 0), fieldsError{})
+
+	if entry.Data["order_id"] != "o-1" {
+		t.Errorf("order_id = %v", entry.Data["order_id"])
+	}
+	if entry.Data["trace"] != "t-1" {
+		t.Errorf("trace overwritten: %v", entry.Data["trace"])
+	}
+	if entry.Data["err_trace"] != "from-error" {
+		t.Errorf("err_trace = %v", entry.Data["err_trace"])
+	}
+	if err, ok := entry.Data[logrus.ErrorKey].(error); !ok || err.Error() != "order failed" {
+		t.Errorf("error overwritten: %v", entry.Data[logrus.ErrorKey])
+	}
+	if entry.Data["err_error"] != "shadow" {
+		t.Errorf("err_error = %v", entry.Data["err_error"])
+	}
+	if entry.Data["stacktrace"] == "fake" || entry.Data["err_stacktrace"] != "fake" {
+		t.Errorf("stacktrace overwritten: %v", entry.Data["stacktrace"])
+	}
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"sync"
+	"sync/atomic"
+)
+
+// staticBase 预先构建的基础日志条目，包含每条日志都相同的字段
+type staticBase struct {
+	server string
+	entry  *logrus.Entry
+}
+
+// staticEntry 当前的基础日志条目，构建元数据变化时清空重建
+var staticEntry atomic.Pointer[staticBase]
+
+var (
+	dockerOnce   sync.Once
+	dockerFields logrus.Fields
+)
+
+// cachedDockerMetadata 进程生命周期内镜像信息不会变化，只获取一次
+func cachedDockerMetadata() logrus.Fields {
+	dockerOnce.Do(func() {
+		image, container, instanceID, err := getDockerMetadata()
+		if err != nil {
+			//log.Printf("Failed to get Docker metadata (when run it on local, can ignore this) %v", err)
+			return
+		}
+		// 只有容器中运行才能获取到相关信息
+		// 并且运行的容器需要挂着配置 /var/run/docker.sock
+		// 例如:
+		// services:
+		//  member:
+		//    image: r2day/member-api:pro
+		//    volumes:
+		//      - /var/run/docker.sock:/var/run/docker.sock
+		dockerFields = logrus.Fields{
+			"image":     image,
+			"container": container,
+			"instance":  instanceID,
+		}
+	})
+	return dockerFields
+}
+
+// staticBaseEntry 返回包含服务名、构建元数据及镜像信息的基础日志条目
+// 这些字段只计算一次，避免每次输出日志时重复构建
+func staticBaseEntry() *logrus.Entry {
+	serverName := viper.GetString("server.name")
+	if base := staticEntry.Load(); base != nil && base.server == serverName {
+		return base.entry
+	}
+	entry := withBuildMetadata(logrus.NewEntry(logger).WithField("server", serverName))
+	if fields := cachedDockerMetadata(); fields != nil {
+		entry = entry.WithFields(fields)
+	}
+	staticEntry.Store(&staticBase{server: serverName, entry: entry})
+	return entry
+}
+
+// resetStaticEntry 静态字段变化后重建基础日志条目
+func resetStaticEntry() {
+	staticEntry.Store(nil)
+}
@@ -0,0 +1,87 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// lineSizeBuckets 日志行大小直方图的桶上限(字节)，最后一个桶为无上限
+var lineSizeBuckets = []int{256, 512, 1024, 2048, 4096, 8192, 16384, 65536}
+
+// LineSizeBucket 直方图中的一个桶
+type LineSizeBucket struct {
+	// 桶上限(字节)，0 表示无上限
+	UpperBound int
+	// 大小不超过上限的日志行数量(累计)
+	Count uint64
+}
+
+// LineStats 日志行大小统计
+type LineStats struct {
+	// 日志行数量
+	Lines uint64
+	// 总字节数
+	TotalBytes uint64
+	// 最大行
+	MaxBytes int
+	// 累计直方图
+	Buckets []LineSizeBucket
+}
+
+// lineStatsEnabled 是否开启日志行大小统计，默认关闭
+var lineStatsEnabled atomic.Bool
+
+var lineStats = struct {
+	sync.Mutex
+	lines   uint64
+	total   uint64
+	max     int
+	buckets []uint64
+}{buckets: make([]uint64, len(lineSizeBuckets)+1)}
+
+// EnableLineStats 开启或关闭日志行大小统计
+// 统计的是主输出格式化后的实际长度，不包括 AddSink 增加的输出目标，可用于发现输出过大日志的服务并设置合理的截断阈值
+func EnableLineStats(enabled bool) {
+	lineStatsEnabled.Store(enabled)
+}
+
+// recordLineSize 记录一行日志的大小
+func recordLineSize(size int) {
+	if !lineStatsEnabled.Load() {
+		return
+	}
+	i := 0
+	for i < len(lineSizeBuckets) && size > lineSizeBuckets[i] {
+		i++
+	}
+	lineStats.Lock()
+	lineStats.lines++
+	lineStats.total += uint64(size)
+	if size > lineStats.max {
+		lineStats.max = size
+	}
+	lineStats.buckets[i]++
+	lineStats.Unlock()
+}
+
+// Stats 返回日志行大小统计
+func Stats() LineStats {
+	lineStats.Lock()
+	defer lineStats.Unlock()
+
+	stats := LineStats{
+		Lines:      lineStats.lines,
+		TotalBytes: lineStats.total,
+		MaxBytes:   lineStats.max,
+		Buckets:    make([]LineSizeBucket, len(lineStats.buckets)),
+	}
+	var cumulative uint64
+	for i, n := range lineStats.buckets {
+		cumulative += n
+		stats.Buckets[i].Count = cumulative
+		if i < len(lineSizeBuckets) {
+			stats.Buckets[i].UpperBound = lineSizeBuckets[i]
+		}
+	}
+	return stats
+}
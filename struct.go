@@ -0,0 +1,89 @@
+package log
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// redactedValue 脱敏后的占位值
+const redactedValue = "******"
+
+// structField 结构体字段的日志元数据
+type structField struct {
+	index  int
+	name   string
+	redact bool
+}
+
+// structFieldsCache 按类型缓存反射解析结果
+var structFieldsCache sync.Map
+
+// LogStruct 将结构体字段按 log 标签展开为日志字段
+// 标签规则:
+//
+//	log:"name"        使用 name 作为字段名
+//	log:"-"           忽略该字段
+//	log:"name,redact" 输出脱敏后的值，可以与其它选项组合，例如 log:"card,omitempty,redact"
+//
+// 未设置标签的导出字段使用字段名
+func LogStruct(ctx context.Context, v interface{}) *logrus.Entry {
+	entry := getBaseEntry(ctx, 1)
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return entry
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return entry.WithField("value", v)
+	}
+
+	fields := make(logrus.Fields)
+	for _, f := range getStructFields(rv.Type()) {
+		if f.redact {
+			fields[f.name] = redactedValue
+			continue
+		}
+		fields[f.name] = rv.Field(f.index).Interface()
+	}
+	return entry.WithFields(fields)
+}
+
+// getStructFields 解析结构体的 log 标签
+func getStructFields(t reflect.Type) []structField {
+	if v, ok := structFieldsCache.Load(t); ok {
+		return v.([]structField)
+	}
+
+	fields := make([]structField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			// 未导出字段
+			continue
+		}
+		tag := sf.Tag.Get("log")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+		field := structField{index: i, name: name}
+		for _, opt := range strings.Split(opts, ",") {
+			if strings.TrimSpace(opt) == "redact" {
+				field.redact = true
+			}
+		}
+		fields = append(fields, field)
+	}
+
+	structFieldsCache.Store(t, fields)
+	return fields
+}
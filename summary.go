@@ -0,0 +1,89 @@
+package log
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// defaultErrorSummaryInterval 默认的汇总间隔
+const defaultErrorSummaryInterval = time.Minute
+
+// errorSummaryHook 统计每个错误指纹出现的次数
+type errorSummaryHook struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// Levels 仅统计 error 及以上级别
+func (h *errorSummaryHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+// Fire 累加当前指纹的计数
+func (h *errorSummaryHook) Fire(entry *logrus.Entry) error {
+	fp := fingerprint(entry)
+	h.mu.Lock()
+	// 已停止的汇总不再计数
+	if h.counts != nil {
+		h.counts[fp]++
+	}
+	h.mu.Unlock()
+	return nil
+}
+
+// reset 返回自上次汇总以来的计数并清零
+func (h *errorSummaryHook) reset() map[string]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	counts := h.counts
+	h.counts = make(map[string]int)
+	return counts
+}
+
+// StartErrorSummary 周期性输出错误汇总
+// 每个周期输出一条 info 日志，包含自上次汇总以来每个错误指纹的出现次数
+// 适用于低流量服务作为心跳信号，interval 小于等于 0 时使用默认值 1 分钟
+// 返回的函数用于停止汇总并移除统计用的 hook
+func StartErrorSummary(interval time.Duration) func() {
+	if interval <= 0 {
+		interval = defaultErrorSummaryInterval
+	}
+	hook := &errorSummaryHook{counts: make(map[string]int)}
+	AddHook(hook)
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				counts := hook.reset()
+				total := 0
+				for _, n := range counts {
+					total += n
+				}
+				getBaseEntry(context.Background(), 0).
+					WithField("errors", counts).
+					WithField("total", total).
+					WithField("interval", interval.String()).
+					Info("error summary")
+			case <-done:
+				ticker.Stop()
+				removeHook(hook)
+				hook.mu.Lock()
+				hook.counts = nil
+				hook.mu.Unlock()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func chainContains(hook *errorSummaryHook) bool {
+	hooks.mu.RLock()
+	defer hooks.mu.RUnlock()
+	for _, h := range hooks.hooks {
+		if h.hook == hook {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStartErrorSummaryRemovesHook(t *testing.T) {
+	// 非法的间隔使用默认值，不会 panic
+	stop := StartErrorSummary(0)
+
+	hooks.mu.RLock()
+	hook, _ := hooks.hooks[len(hooks.hooks)-1].hook.(*errorSummaryHook)
+	hooks.mu.RUnlock()
+	if hook == nil || !chainContains(hook) {
+		t.Fatal("summary hook not registered")
+	}
+
+	stop()
+	deadline := time.Now().Add(time.Second)
+	for chainContains(hook) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if chainContains(hook) {
+		t.Error("summary hook not removed after stop")
+	}
+}
@@ -0,0 +1,18 @@
+package log
+
+import (
+	"context"
+)
+
+// msgTemplateKey 未渲染的格式化模板，便于按模板聚合日志
+const msgTemplateKey = "msg_template"
+
+// Infof 输出格式化的 info 日志，同时记录 msg_template
+func Infof(ctx context.Context, format string, args ...interface{}) {
+	getBaseEntry(ctx, 1).WithField(msgTemplateKey, format).Infof(format, args...)
+}
+
+// Warnf 输出格式化的 warn 日志，同时记录 msg_template
+func Warnf(ctx context.Context, format string, args ...interface{}) {
+	getBaseEntry(ctx, 1).WithField(msgTemplateKey, format).Warnf(format, args...)
+}
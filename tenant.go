@@ -0,0 +1,24 @@
+package log
+
+import (
+	"context"
+)
+
+// tenantPath 多级租户 组织 -> 商户 -> 门店
+type tenantPath struct {
+	org      string
+	merchant string
+	store    string
+}
+
+// WithTenantPath 在上下文中设置完整的租户层级
+// 日志会输出 org_id/merchant_id/store_id 字段，操作日志同样会记录，便于按任意层级过滤
+func WithTenantPath(ctx context.Context, org, merchant, store string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenantPath{org: org, merchant: merchant, store: store})
+}
+
+// tenantPathFrom 返回上下文中的租户层级
+func tenantPathFrom(ctx context.Context) (tenantPath, bool) {
+	tp, ok := ctx.Value(tenantKey).(tenantPath)
+	return tp, ok
+}
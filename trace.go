@@ -0,0 +1,118 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"strings"
+	"sync/atomic"
+)
+
+// idGenerator 生成 id 的函数，未设置时使用 defaultIDGenerator
+var idGenerator atomic.Pointer[func() string]
+
+// defaultIDGenerator 默认生成 16 字节随机十六进制 id
+func defaultIDGenerator() string {
+	return randomHex(16)
+}
+
+// SetIDGenerator 设置自定义 id 生成器(ULID/KSUID/Snowflake 等)
+// 用于 NewTraceID 生成 trace id；操作日志仍使用 Mongo ObjectID 作为主键
+// 传入 nil 时恢复默认生成器
+func SetIDGenerator(gen func() string) {
+	if gen == nil {
+		idGenerator.Store(nil)
+		return
+	}
+	idGenerator.Store(&gen)
+}
+
+// NewTraceID 生成新的 trace id
+// 当配置了 server.name 时以服务名作为前缀，例如: orderapi-7f3a...
+// 便于在多服务混合的日志中快速识别请求来源
+func NewTraceID() string {
+	gen := defaultIDGenerator
+	if p := idGenerator.Load(); p != nil {
+		gen = *p
+	}
+	id := gen()
+	prefix := traceIDPrefix(viper.GetString("server.name"))
+	if prefix == "" {
+		return id
+	}
+	return prefix + "-" + id
+}
+
+// traceIDPrefix 仅保留服务名中的小写字母和数字
+// 保证 trace id 在 header 中传递时格式合法
+func traceIDPrefix(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// randomHex 返回 n 字节随机数的十六进制表示
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return strings.Repeat("0", n*2)
+	}
+	return hex.EncodeToString(b)
+}
+
+const (
+	// MissingTraceOmit 缺少 trace id 时不输出 trace 字段（默认）
+	MissingTraceOmit = "omit"
+	// MissingTracePlaceholder 缺少 trace id 时输出占位符 no-trace
+	MissingTracePlaceholder = "placeholder"
+	// MissingTraceGenerate 缺少 trace id 时自动生成
+	MissingTraceGenerate = "generate"
+
+	// noTracePlaceholder 占位符
+	noTracePlaceholder = "no-trace"
+)
+
+// missingTracePolicy 上下文中缺少 trace id 时的处理方式，未设置时为 omit
+var missingTracePolicy atomic.Value
+
+// SetMissingTracePolicy 设置上下文中缺少 trace id 时的处理方式
+// 支持: omit (默认), placeholder, generate
+func SetMissingTracePolicy(policy string) error {
+	switch policy {
+	case MissingTraceOmit, MissingTracePlaceholder, MissingTraceGenerate:
+		missingTracePolicy.Store(policy)
+		return nil
+	default:
+		return fmt.Errorf("unknown missing trace policy: %s", policy)
+	}
+}
+
+// missingTraceID 根据策略返回缺失 trace id 时使用的值，空字符串表示不输出
+func missingTraceID() string {
+	policy, _ := missingTracePolicy.Load().(string)
+	switch policy {
+	case MissingTracePlaceholder:
+		return noTracePlaceholder
+	case MissingTraceGenerate:
+		return NewTraceID()
+	default:
+		return ""
+	}
+}
+
+// LogWithTrace 返回使用指定 trace/span id 的日志条目，不从上下文中读取 trace id
+// 适用于 Kafka/NATS 等消息消费者从消息头中取得 trace id 的场景
+func LogWithTrace(ctx context.Context, traceID, spanID string) *logrus.Entry {
+	entry := getBaseEntry(ctx, 1).WithField("trace", traceID)
+	if spanID != "" {
+		entry = entry.WithField("span", spanID)
+	}
+	return entry
+}
@@ -0,0 +1,141 @@
+package log
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// unixSocketRetryInterval 重连间隔
+	unixSocketRetryInterval = time.Second
+	// unixSocketBufferSize 断线期间最多缓存的字节数
+	unixSocketBufferSize = 1 << 20
+	// unixSocketWriteTimeout 单次写入超时，避免采集端阻塞时拖慢业务
+	unixSocketWriteTimeout = time.Second
+)
+
+// UnixSocketWriter 将日志写入 Unix domain socket 上监听的采集端
+// 采集端不可用(尚未启动或重启中)时先缓存，超出缓存上限后丢弃并计数，并定期重连
+type UnixSocketWriter struct {
+	path string
+
+	mu        sync.Mutex
+	conn      net.Conn
+	lastDial  time.Time
+	pending   [][]byte
+	pendingSz int
+	closed    bool
+
+	dropped atomic.Uint64
+}
+
+// NewUnixSocketWriter 创建写入 Unix socket 的 writer，可直接传给 Init
+// 启动时 socket 不存在不会返回错误，而是在后续写入时重试连接
+func NewUnixSocketWriter(path string) (*UnixSocketWriter, error) {
+	if path == "" {
+		return nil, errors.New("unix socket path is empty")
+	}
+	w := &UnixSocketWriter{path: path}
+	w.mu.Lock()
+	w.dial()
+	w.mu.Unlock()
+	return w, nil
+}
+
+// Write 写入一行日志，未连接时缓存
+// 为避免 logrus 每行输出写入错误，缓存或丢弃时同样返回成功
+func (w *UnixSocketWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errors.New("unix socket writer is closed")
+	}
+	if w.conn == nil && time.Since(w.lastDial) >= unixSocketRetryInterval {
+		w.dial()
+	}
+	if w.conn != nil && w.flush() {
+		if w.write(p) {
+			return len(p), nil
+		}
+	}
+	w.buffer(p)
+	return len(p), nil
+}
+
+// Dropped 返回因缓存已满或只写入了一部分被丢弃的日志行数
+func (w *UnixSocketWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Close 关闭连接，尚未发送的缓存会被丢弃
+func (w *UnixSocketWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	w.dropped.Add(uint64(len(w.pending)))
+	w.pending = nil
+	w.pendingSz = 0
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// dial 尝试连接，调用方需持有锁
+func (w *UnixSocketWriter) dial() {
+	w.lastDial = time.Now()
+	conn, err := net.DialTimeout("unix", w.path, unixSocketWriteTimeout)
+	if err != nil {
+		return
+	}
+	w.conn = conn
+}
+
+// write 写入连接，失败时断开以便重连，调用方需持有锁
+// 返回 false 表示没有写入任何数据，需要缓存后在新连接上重发
+// 已写入一部分的行无法在新连接上补全，丢弃并计入 Dropped
+func (w *UnixSocketWriter) write(p []byte) bool {
+	_ = w.conn.SetWriteDeadline(time.Now().Add(unixSocketWriteTimeout))
+	n, err := w.conn.Write(p)
+	if err == nil {
+		return true
+	}
+	_ = w.conn.Close()
+	w.conn = nil
+	if n > 0 {
+		w.dropped.Add(1)
+		return true
+	}
+	return false
+}
+
+// flush 发送断线期间缓存的日志，返回连接是否仍然可用，调用方需持有锁
+func (w *UnixSocketWriter) flush() bool {
+	for len(w.pending) > 0 && w.conn != nil {
+		if !w.write(w.pending[0]) {
+			return false
+		}
+		w.pendingSz -= len(w.pending[0])
+		w.pending = w.pending[1:]
+	}
+	return w.conn != nil
+}
+
+// buffer 缓存一行日志，超出上限时丢弃，调用方需持有锁
+func (w *UnixSocketWriter) buffer(p []byte) {
+	if w.pendingSz+len(p) > unixSocketBufferSize {
+		w.dropped.Add(1)
+		return
+	}
+	// logrus 会复用 p 的底层缓冲区，需要复制
+	line := make([]byte, len(p))
+	copy(line, p)
+	w.pending = append(w.pending, line)
+	w.pendingSz += len(line)
+}
@@ -0,0 +1,24 @@
+package log
+
+import (
+	"context"
+)
+
+// workflow 跨服务、跨请求的 saga/工作流
+type workflow struct {
+	id   string
+	step string
+}
+
+// WithWorkflow 在上下文中设置工作流 id 及当前步骤
+// 日志会输出 workflow_id/step_name 字段，操作日志及登录日志同样会记录
+// trace 只关联单个请求，而一个 saga 跨越多个请求，workflow_id 用于端到端还原整个流程
+func WithWorkflow(ctx context.Context, wfID, step string) context.Context {
+	return context.WithValue(ctx, workflowKey, workflow{id: wfID, step: step})
+}
+
+// workflowFrom 返回上下文中的工作流
+func workflowFrom(ctx context.Context) (workflow, bool) {
+	wf, ok := ctx.Value(workflowKey).(workflow)
+	return wf, ok
+}